@@ -0,0 +1,108 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ACRCredentialsSpec defines the desired state of ACRCredentials
+type ACRCredentialsSpec struct {
+	// Registry is the login server of the Azure Container Registry, e.g. myregistry.azurecr.io
+	//+kubebuilder:validation:Required
+	Registry string `json:"registry"`
+
+	// TenantID is the Azure Active Directory tenant of the service principal
+	//+kubebuilder:validation:Required
+	TenantID string `json:"tenantId"`
+
+	// ServicePrincipalSecretRef references a Secret in the same namespace holding
+	// the client ID and secret of the service principal.
+	//+kubebuilder:validation:Required
+	ServicePrincipalSecretRef ServicePrincipalSecretReference `json:"servicePrincipalSecretRef"`
+
+	// AuthorityURL overrides the Azure Active Directory endpoint, e.g. for sovereign clouds.
+	// Defaults to https://login.microsoftonline.com.
+	//+kubebuilder:validation:Optional
+	AuthorityURL string `json:"authorityUrl,omitempty"`
+
+	// RegistryURL overrides the URL of the registry token exchange endpoints. Defaults to https://<registry>.
+	//+kubebuilder:validation:Optional
+	RegistryURL string `json:"registryUrl,omitempty"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// ServicePrincipalSecretReference selects the keys of a Secret holding an Azure service principal
+type ServicePrincipalSecretReference struct {
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=clientId
+	ClientIDKey string `json:"clientIdKey,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=clientSecret
+	ClientSecretKey string `json:"clientSecretKey,omitempty"`
+}
+
+// ACRCredentialsStatus defines the observed state of ACRCredentials
+type ACRCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Registry",type=string,JSONPath=`.spec.registry`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ACRCredentials is the Schema for the acrcredentials API
+type ACRCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ACRCredentialsSpec   `json:"spec,omitempty"`
+	Status ACRCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ACRCredentialsList contains a list of ACRCredentials
+type ACRCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ACRCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *ACRCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *ACRCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&ACRCredentials{}, &ACRCredentialsList{})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var acrcredentialslog = logf.Log.WithName("acrcredentials-resource")
+
+func (r *ACRCredentials) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-registry-astrokube-com-v1alpha1-acrcredentials,mutating=false,failurePolicy=fail,sideEffects=None,groups=registry.astrokube.com,resources=acrcredentials,verbs=create;update,versions=v1alpha1,name=vacrcredentials.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &ACRCredentials{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ACRCredentials) ValidateCreate() error {
+	acrcredentialslog.Info("validate create", "name", r.Name)
+
+	return r.validateACRCredentials()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ACRCredentials) ValidateUpdate(old runtime.Object) error {
+	acrcredentialslog.Info("validate update", "name", r.Name)
+
+	return r.validateACRCredentials()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ACRCredentials) ValidateDelete() error {
+	acrcredentialslog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *ACRCredentials) validateACRCredentials() error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateRegistryHosts(field.NewPath("spec", "registry"), []string{r.Spec.Registry})...)
+	if r.Spec.TenantID == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "tenantId"), ""))
+	}
+	if r.Spec.ServicePrincipalSecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "servicePrincipalSecretRef", "name"), ""))
+	}
+	if r.Spec.AuthorityURL != "" {
+		allErrs = append(allErrs, validateURL(field.NewPath("spec", "authorityUrl"), r.Spec.AuthorityURL)...)
+	}
+	if r.Spec.RegistryURL != "" {
+		allErrs = append(allErrs, validateURL(field.NewPath("spec", "registryUrl"), r.Spec.RegistryURL)...)
+	}
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "ACRCredentials"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// AWSAuthentication defines how the controller authenticates against AWS
+type AWSAuthentication struct {
+	//+kubebuilder:validation:Optional
+	AccessKeyID string `json:"accessKeyId,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+
+	// AccessKeySecretRef references a Secret in the same namespace holding the
+	// AWS Access Key. It is mutually exclusive with AccessKeyID and SecretAccessKey.
+	//+kubebuilder:validation:Optional
+	AccessKeySecretRef *AccessKeySecretReference `json:"accessKeySecretRef,omitempty"`
+
+	// ServiceAccountName is a ServiceAccount in the same namespace whose token
+	// is exchanged for the RoleArn credentials through STS AssumeRoleWithWebIdentity.
+	// It is mutually exclusive with the AWS Access Key fields.
+	//+kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Vault reads the AWS Access Key from a KV secret with the accessKeyId, secretAccessKey
+	// and optional sessionToken keys, or requests it to the AWS secrets engine. It is
+	// mutually exclusive with the AWS Access Key fields and ServiceAccountName.
+	//+kubebuilder:validation:Optional
+	Vault *VaultSource `json:"vault,omitempty"`
+
+	// RoleArn is the IAM Role assumed with the ServiceAccount token, or on top
+	// of the AWS Access Key when no ServiceAccountName is set.
+	//+kubebuilder:validation:Optional
+	RoleArn string `json:"roleArn,omitempty"`
+
+	// ExternalID is passed to STS AssumeRole when assuming RoleArn with an AWS Access Key.
+	//+kubebuilder:validation:Optional
+	ExternalID string `json:"externalId,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:MaxLength=64
+	//+kubebuilder:validation:Pattern=`^[\w+=,.@-]*$`
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+
+	// SessionPolicy is an inline IAM policy in JSON restricting the permissions
+	// of the assumed RoleArn session.
+	//+kubebuilder:validation:Optional
+	SessionPolicy string `json:"sessionPolicy,omitempty"`
+}
+
+// AccessKeySecretReference selects the keys of a Secret holding an AWS Access Key
+type AccessKeySecretReference struct {
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=accessKeyId
+	AccessKeyIDKey string `json:"accessKeyIdKey,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=secretAccessKey
+	SecretAccessKeyKey string `json:"secretAccessKeyKey,omitempty"`
+}
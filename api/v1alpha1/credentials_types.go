@@ -0,0 +1,164 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CredentialsSpec defines the settings shared by every credentials kind
+type CredentialsSpec struct {
+	// RefreshBefore is how long before the token expiration it is refreshed.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="1h"
+	RefreshBefore *metav1.Duration `json:"refreshBefore,omitempty"`
+
+	// Suspend stops the token refreshes, keeping the generated Secret as is.
+	//+kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// SecretTemplate customizes the generated Secret
+	//+kubebuilder:validation:Optional
+	SecretTemplate *SecretTemplate `json:"secretTemplate,omitempty"`
+
+	// DeletionPolicy defines whether the generated secrets are deleted or
+	// orphaned when the credentials are deleted.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	ImageSelector []string `json:"imageSelector,omitempty"`
+}
+
+// DeletionPolicy defines what happens to the generated secrets when the credentials are deleted
+//+kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the generated secrets
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the generated secrets, releasing them from their owner
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// CredentialsStatus defines the observed state shared by every credentials kind
+type CredentialsStatus struct {
+	//+kubebuilder:validation:Optional
+	Phase CredentialsPhase `json:"phase,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// ExpiresAt is the expiration time of the current token
+	//+kubebuilder:validation:Optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// LastRefreshTime is the last time the token was refreshed
+	//+kubebuilder:validation:Optional
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+
+	// RegistryHosts are the registries the token is valid for
+	//+kubebuilder:validation:Optional
+	RegistryHosts []string `json:"registryHosts,omitempty"`
+
+	// SecretName is the name of the generated Secret
+	//+kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// SecretHash is the hash of the generated Secret data, used to detect drift
+	//+kubebuilder:validation:Optional
+	SecretHash string `json:"secretHash,omitempty"`
+
+	// ObservedGeneration is the last generation reconciled by the controller
+	//+kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastHandledRefreshRequest is the value of the refresh-requested-at
+	// annotation handled by the last refresh
+	//+kubebuilder:validation:Optional
+	LastHandledRefreshRequest string `json:"lastHandledRefreshRequest,omitempty"`
+
+	// Conditions represent the latest observations of the credentials state
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CredentialsPhase is a summary of the status conditions
+type CredentialsPhase string
+
+const (
+	CredentialsAuthenticating CredentialsPhase = "Authenticating"
+	CredentialsUnauthorized   CredentialsPhase = "Unauthorized"
+	CredentialsError          CredentialsPhase = "Error"
+	CredentialsAuthenticated  CredentialsPhase = "Authenticated"
+	CredentialsTerminating    CredentialsPhase = "Terminating"
+	CredentialsSuspended      CredentialsPhase = "Suspended"
+	CredentialsDegraded       CredentialsPhase = "Degraded"
+)
+
+// ECRCredentialsPhase is the former name of CredentialsPhase
+// +kubebuilder:object:generate=false
+type ECRCredentialsPhase = CredentialsPhase
+
+// Former names of the credentials phases
+const (
+	ECRCredentialsAuthenticating = CredentialsAuthenticating
+	ECRCredentialsUnauthorized   = CredentialsUnauthorized
+	ECRCredentialsError          = CredentialsError
+	ECRCredentialsAuthenticated  = CredentialsAuthenticated
+	ECRCredentialsTerminating    = CredentialsTerminating
+	ECRCredentialsSuspended      = CredentialsSuspended
+	ECRCredentialsDegraded       = CredentialsDegraded
+)
+
+// RefreshRequestedAtAnnotation requests an immediate token refresh when its value changes,
+// e.g. kubectl annotate ecrcredentials sample registry.astrokube.com/refresh-requested-at="$(date +%s)" --overwrite
+const RefreshRequestedAtAnnotation = "registry.astrokube.com/refresh-requested-at"
+
+// Condition types
+const (
+	// ConditionReady is True when the generated Secret holds valid credentials
+	ConditionReady = "Ready"
+	// ConditionAuthenticated is True when the last authentication succeeded
+	ConditionAuthenticated = "Authenticated"
+	// ConditionSecretSynced is True when the generated Secret is up to date
+	ConditionSecretSynced = "SecretSynced"
+	// ConditionDegraded is True when the last reconciliation failed. Ready stays
+	// True while the last token is valid.
+	ConditionDegraded = "Degraded"
+	// ConditionSuspended is True when the token refreshes are suspended
+	ConditionSuspended = "Suspended"
+)
+
+// Condition reasons
+const (
+	ReasonAuthenticating       = "Authenticating"
+	ReasonAuthenticated        = "Authenticated"
+	ReasonUnauthorized         = "Unauthorized"
+	ReasonAuthenticationFailed = "AuthenticationFailed"
+	ReasonSecretSynced         = "SecretSynced"
+	ReasonSecretSyncFailed     = "SecretSyncFailed"
+	ReasonReconciled           = "Reconciled"
+	ReasonTerminating          = "Terminating"
+	ReasonSuspended            = "Suspended"
+	ReasonResumed              = "Resumed"
+	ReasonTokenExpired         = "TokenExpired"
+	ReasonConflict             = "Conflict"
+)
@@ -25,41 +25,59 @@ import (
 
 // ECRCredentialsSpec defines the desired state of ECRCredentials
 type ECRCredentialsSpec struct {
-	//+kubebuilder:validation:Required
-	AccessKeyID string `json:"accessKeyId"`
-
-	//+kubebuilder:validation:Required
-	SecretAccessKey string `json:"secretAccessKey"`
+	AWSAuthentication `json:",inline"`
 
 	//+kubebuilder:validation:Required
 	Region string `json:"region"`
 
+	// RegistryIDs are the AWS account IDs of the registries to get credentials for.
+	// Defaults to the registry of the authenticated account.
+	//+kubebuilder:validation:Optional
+	RegistryIDs []RegistryID `json:"registryIds,omitempty"`
+
+	// Endpoints overrides the AWS service endpoints, e.g. to use FIPS or VPC endpoints.
 	//+kubebuilder:validation:Optional
-	ImageSelector []string `json:"imageSelector,omitempty"`
+	Endpoints *AWSEndpoints `json:"endpoints,omitempty"`
+
+	CredentialsSpec `json:",inline"`
 }
 
-// ECRCredentialsStatus defines the observed state of ECRCredentials
-type ECRCredentialsStatus struct {
+// RegistryID is the AWS account ID owning an ECR registry
+//+kubebuilder:validation:Pattern=`^[0-9]{12}$`
+type RegistryID string
+
+// AWSEndpoints defines custom URLs for the AWS services used by the controller
+type AWSEndpoints struct {
 	//+kubebuilder:validation:Optional
-	Phase ECRCredentialsPhase `json:"phase,omitempty"`
+	ECR string `json:"ecr,omitempty"`
 
 	//+kubebuilder:validation:Optional
-	ErrorMessage string `json:"errorMessage,omitempty"`
+	STS string `json:"sts,omitempty"`
 }
 
-type ECRCredentialsPhase string
+// ECRCredentialsStatus defines the observed state of ECRCredentials
+type ECRCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+
+	// AWSAccountID is the account of the authenticated identity
+	//+kubebuilder:validation:Optional
+	AWSAccountID string `json:"awsAccountId,omitempty"`
 
-var (
-	ECRCredentialsAuthenticating ECRCredentialsPhase = "Authenticating"
-	ECRCredentialsUnauthorized   ECRCredentialsPhase = "Unauthorized"
-	ECRCredentialsError          ECRCredentialsPhase = "Error"
-	ECRCredentialsAuthenticated  ECRCredentialsPhase = "Authenticated"
-	ECRCredentialsTerminating    ECRCredentialsPhase = "Terminanting"
-)
+	// CallerArn is the ARN of the authenticated identity
+	//+kubebuilder:validation:Optional
+	CallerArn string `json:"callerArn,omitempty"`
+}
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Registry",type=string,JSONPath=`.status.registryHosts[0]`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+//+kubebuilder:printcolumn:name="Account",type=string,JSONPath=`.status.awsAccountId`,priority=1
+//+kubebuilder:printcolumn:name="Caller",type=string,JSONPath=`.status.callerArn`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ECRCredentials is the Schema for the ecrcredentials API
 type ECRCredentials struct {
@@ -79,6 +97,16 @@ type ECRCredentialsList struct {
 	Items           []ECRCredentials `json:"items"`
 }
 
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *ECRCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *ECRCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
 func init() {
 	SchemeBuilder.Register(&ECRCredentials{}, &ECRCredentialsList{})
 }
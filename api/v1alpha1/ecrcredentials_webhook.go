@@ -17,7 +17,15 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -43,16 +51,14 @@ var _ webhook.Validator = &ECRCredentials{}
 func (r *ECRCredentials) ValidateCreate() error {
 	ecrcredentialslog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil
+	return r.validateECRCredentials()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *ECRCredentials) ValidateUpdate(old runtime.Object) error {
 	ecrcredentialslog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil
+	return r.validateECRCredentials()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -62,3 +68,147 @@ func (r *ECRCredentials) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+func (r *ECRCredentials) validateECRCredentials() error {
+	allErrs := r.Spec.AWSAuthentication.validate(field.NewPath("spec"))
+	if r.Spec.ServiceAccountName != "" && r.Spec.Endpoints != nil && r.Spec.Endpoints.STS != "" {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "endpoints", "sts"),
+			"may not be set together with serviceAccountName, ServiceAccount tokens are only sent to the AWS STS endpoints"))
+	}
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "ECRCredentials"},
+		r.Name, allErrs)
+}
+
+func (s *AWSAuthentication) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	inline := s.AccessKeyID != "" || s.SecretAccessKey != ""
+	switch {
+	case s.Vault != nil && (inline || s.AccessKeySecretRef != nil || s.ServiceAccountName != ""):
+		allErrs = append(allErrs, field.Forbidden(path.Child("vault"),
+			"may not be set together with an AWS Access Key or serviceAccountName"))
+	case s.Vault != nil:
+		allErrs = append(allErrs, s.Vault.validate(path.Child("vault"), true)...)
+	case inline && s.AccessKeySecretRef != nil:
+		allErrs = append(allErrs, field.Forbidden(path.Child("accessKeySecretRef"),
+			"may not be set together with accessKeyId and secretAccessKey"))
+	case s.ServiceAccountName != "" && (inline || s.AccessKeySecretRef != nil):
+		allErrs = append(allErrs, field.Forbidden(path.Child("serviceAccountName"),
+			"may not be set together with an AWS Access Key"))
+	case s.ServiceAccountName != "":
+		if s.RoleArn == "" {
+			allErrs = append(allErrs, field.Required(path.Child("roleArn"),
+				"roleArn must be set together with serviceAccountName"))
+		}
+		if s.ExternalID != "" {
+			allErrs = append(allErrs, field.Forbidden(path.Child("externalId"),
+				"may not be set together with serviceAccountName"))
+		}
+		if s.SessionPolicy != "" {
+			allErrs = append(allErrs, field.Forbidden(path.Child("sessionPolicy"),
+				"may not be set together with serviceAccountName"))
+		}
+	case s.AccessKeySecretRef != nil:
+		if s.AccessKeySecretRef.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("accessKeySecretRef", "name"), ""))
+		}
+	case s.AccessKeyID == "":
+		allErrs = append(allErrs, field.Required(path.Child("accessKeyId"),
+			"accessKeyId and secretAccessKey, accessKeySecretRef, serviceAccountName or vault must be set"))
+	case s.SecretAccessKey == "":
+		allErrs = append(allErrs, field.Required(path.Child("secretAccessKey"),
+			"accessKeyId and secretAccessKey, accessKeySecretRef, serviceAccountName or vault must be set"))
+	}
+
+	if s.RoleArn == "" {
+		for name, value := range map[string]string{
+			"externalId":      s.ExternalID,
+			"roleSessionName": s.RoleSessionName,
+			"sessionPolicy":   s.SessionPolicy,
+		} {
+			if value != "" {
+				allErrs = append(allErrs, field.Forbidden(path.Child(name), "may only be set together with roleArn"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validate checks the Vault source. The AWS secrets engine is only allowed for AWS authentications.
+func (s *VaultSource) validate(path *field.Path, allowAWS bool) field.ErrorList {
+	allErrs := validateURL(path.Child("address"), s.Address)
+
+	if s.Auth.ServiceAccountName == "" {
+		allErrs = append(allErrs, field.Required(path.Child("auth", "serviceAccountName"), ""))
+	}
+	if s.Auth.Role == "" {
+		allErrs = append(allErrs, field.Required(path.Child("auth", "role"), ""))
+	}
+
+	switch {
+	case s.KV != nil && s.AWS != nil:
+		allErrs = append(allErrs, field.Forbidden(path.Child("aws"), "may not be set together with kv"))
+	case s.AWS != nil && !allowAWS:
+		allErrs = append(allErrs, field.Forbidden(path.Child("aws"), "is only allowed for AWS credentials"))
+	case s.AWS != nil && s.AWS.Role == "":
+		allErrs = append(allErrs, field.Required(path.Child("aws", "role"), ""))
+	case s.KV != nil && s.KV.Path == "":
+		allErrs = append(allErrs, field.Required(path.Child("kv", "path"), ""))
+	case s.KV == nil && s.AWS == nil:
+		if allowAWS {
+			allErrs = append(allErrs, field.Required(path.Child("kv"), "kv or aws must be set"))
+		} else {
+			allErrs = append(allErrs, field.Required(path.Child("kv"), ""))
+		}
+	}
+
+	return allErrs
+}
+
+func (s *SecretTemplate) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if s.Name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(s.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("name"), s.Name, msg))
+		}
+	}
+
+	format := s.Format
+	if format == "" {
+		format = SecretFormatDockerConfigJSON
+	}
+	if s.Type == corev1.SecretTypeDockerConfigJson && format != SecretFormatDockerConfigJSON {
+		allErrs = append(allErrs, field.Invalid(path.Child("type"), s.Type,
+			"may only be set together with the dockerconfigjson format"))
+	}
+
+	generatedKeys := map[string]bool{}
+	for _, key := range SecretFormatKeys[format] {
+		generatedKeys[key] = true
+	}
+
+	for key, value := range s.Data {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			allErrs = append(allErrs, field.Invalid(path.Child("data").Key(key), key, msg))
+		}
+		if generatedKeys[key] {
+			allErrs = append(allErrs, field.Forbidden(path.Child("data").Key(key),
+				fmt.Sprintf("is generated by the controller for the %s format", format)))
+		}
+		if _, err := template.New(key).Parse(value); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("data").Key(key), value, err.Error()))
+		}
+	}
+
+	return allErrs
+}
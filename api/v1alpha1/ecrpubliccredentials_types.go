@@ -0,0 +1,72 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ECRPublicCredentialsSpec defines the desired state of ECRPublicCredentials
+type ECRPublicCredentialsSpec struct {
+	AWSAuthentication `json:",inline"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// ECRPublicCredentialsStatus defines the observed state of ECRPublicCredentials
+type ECRPublicCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ECRPublicCredentials is the Schema for the ecrpubliccredentials API
+type ECRPublicCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ECRPublicCredentialsSpec   `json:"spec,omitempty"`
+	Status ECRPublicCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ECRPublicCredentialsList contains a list of ECRPublicCredentials
+type ECRPublicCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ECRPublicCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *ECRPublicCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *ECRPublicCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&ECRPublicCredentials{}, &ECRPublicCredentialsList{})
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var ecrpubliccredentialslog = logf.Log.WithName("ecrpubliccredentials-resource")
+
+func (r *ECRPublicCredentials) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-registry-astrokube-com-v1alpha1-ecrpubliccredentials,mutating=false,failurePolicy=fail,sideEffects=None,groups=registry.astrokube.com,resources=ecrpubliccredentials,verbs=create;update,versions=v1alpha1,name=vecrpubliccredentials.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &ECRPublicCredentials{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ECRPublicCredentials) ValidateCreate() error {
+	ecrpubliccredentialslog.Info("validate create", "name", r.Name)
+
+	return r.validateECRPublicCredentials()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ECRPublicCredentials) ValidateUpdate(old runtime.Object) error {
+	ecrpubliccredentialslog.Info("validate update", "name", r.Name)
+
+	return r.validateECRPublicCredentials()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ECRPublicCredentials) ValidateDelete() error {
+	ecrpubliccredentialslog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *ECRPublicCredentials) validateECRPublicCredentials() error {
+	allErrs := r.Spec.AWSAuthentication.validate(field.NewPath("spec"))
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "ECRPublicCredentials"},
+		r.Name, allErrs)
+}
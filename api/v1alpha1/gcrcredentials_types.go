@@ -0,0 +1,101 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GCRCredentialsSpec defines the desired state of GCRCredentials
+type GCRCredentialsSpec struct {
+	// ServiceAccountKeySecretRef references a Secret in the same namespace holding
+	// the JSON key of the Google Cloud service account.
+	//+kubebuilder:validation:Required
+	ServiceAccountKeySecretRef ServiceAccountKeySecretReference `json:"serviceAccountKeySecretRef"`
+
+	// Registries are the Container Registry and Artifact Registry hosts the
+	// access token is written for, e.g. gcr.io or europe-docker.pkg.dev.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default={"gcr.io"}
+	Registries []string `json:"registries,omitempty"`
+
+	// TokenURL overrides the OAuth2 token endpoint. Defaults to the token_uri
+	// of the service account key, or https://oauth2.googleapis.com/token.
+	//+kubebuilder:validation:Optional
+	TokenURL string `json:"tokenUrl,omitempty"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// ServiceAccountKeySecretReference selects the key of a Secret holding a Google Cloud service account JSON key
+type ServiceAccountKeySecretReference struct {
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=key.json
+	Key string `json:"key,omitempty"`
+}
+
+// GCRCredentialsStatus defines the observed state of GCRCredentials
+type GCRCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+
+	// ClientEmail is the email of the authenticated service account
+	//+kubebuilder:validation:Optional
+	ClientEmail string `json:"clientEmail,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GCRCredentials is the Schema for the gcrcredentials API
+type GCRCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GCRCredentialsSpec   `json:"spec,omitempty"`
+	Status GCRCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GCRCredentialsList contains a list of GCRCredentials
+type GCRCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCRCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *GCRCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *GCRCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&GCRCredentials{}, &GCRCredentialsList{})
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var gcrcredentialslog = logf.Log.WithName("gcrcredentials-resource")
+
+func (r *GCRCredentials) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-registry-astrokube-com-v1alpha1-gcrcredentials,mutating=false,failurePolicy=fail,sideEffects=None,groups=registry.astrokube.com,resources=gcrcredentials,verbs=create;update,versions=v1alpha1,name=vgcrcredentials.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &GCRCredentials{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *GCRCredentials) ValidateCreate() error {
+	gcrcredentialslog.Info("validate create", "name", r.Name)
+
+	return r.validateGCRCredentials()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *GCRCredentials) ValidateUpdate(old runtime.Object) error {
+	gcrcredentialslog.Info("validate update", "name", r.Name)
+
+	return r.validateGCRCredentials()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *GCRCredentials) ValidateDelete() error {
+	gcrcredentialslog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *GCRCredentials) validateGCRCredentials() error {
+	var allErrs field.ErrorList
+
+	if r.Spec.ServiceAccountKeySecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "serviceAccountKeySecretRef", "name"), ""))
+	}
+	allErrs = append(allErrs, validateRegistryHosts(field.NewPath("spec", "registries"), r.Spec.Registries)...)
+	if r.Spec.TokenURL != "" {
+		allErrs = append(allErrs, validateURL(field.NewPath("spec", "tokenUrl"), r.Spec.TokenURL)...)
+	}
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "GCRCredentials"},
+		r.Name, allErrs)
+}
+
+// validateRegistryHosts checks that every host is a DNS name with an optional port
+func validateRegistryHosts(path *field.Path, hosts []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, host := range hosts {
+		u, err := url.Parse("https://" + host)
+		if err != nil || u.Host != host {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), host, "must be a registry host, e.g. gcr.io"))
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(u.Hostname()) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), host, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateURL checks that value is an absolute http or https URL
+func validateURL(path *field.Path, value string) field.ErrorList {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return field.ErrorList{field.Invalid(path, value, "must be an absolute http or https URL")}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GHCRCredentialsSpec defines the desired state of GHCRCredentials
+type GHCRCredentialsSpec struct {
+	// AppID is the ID of the GitHub App
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Minimum=1
+	AppID int64 `json:"appId"`
+
+	// InstallationID is the ID of the GitHub App installation in the organization owning the packages
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Minimum=1
+	InstallationID int64 `json:"installationId"`
+
+	// PrivateKeySecretRef references a Secret in the same namespace holding the
+	// PEM encoded private key of the GitHub App.
+	//+kubebuilder:validation:Required
+	PrivateKeySecretRef PrivateKeySecretReference `json:"privateKeySecretRef"`
+
+	// APIURL overrides the GitHub API URL, e.g. https://github.example.com/api/v3
+	// for GitHub Enterprise Server. Defaults to https://api.github.com.
+	//+kubebuilder:validation:Optional
+	APIURL string `json:"apiUrl,omitempty"`
+
+	// Registry is the container registry host the installation token is written for
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=ghcr.io
+	Registry string `json:"registry,omitempty"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// PrivateKeySecretReference selects the key of a Secret holding a PEM encoded private key
+type PrivateKeySecretReference struct {
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=private-key.pem
+	Key string `json:"key,omitempty"`
+}
+
+// GHCRCredentialsStatus defines the observed state of GHCRCredentials
+type GHCRCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GHCRCredentials is the Schema for the ghcrcredentials API
+type GHCRCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GHCRCredentialsSpec   `json:"spec,omitempty"`
+	Status GHCRCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GHCRCredentialsList contains a list of GHCRCredentials
+type GHCRCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GHCRCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *GHCRCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *GHCRCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&GHCRCredentials{}, &GHCRCredentialsList{})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HarborRobotCredentialsSpec defines the desired state of HarborRobotCredentials
+type HarborRobotCredentialsSpec struct {
+	// Registry is the host of the Harbor registry, with an optional port, e.g. harbor.example.com
+	//+kubebuilder:validation:Required
+	Registry string `json:"registry"`
+
+	// URL overrides the URL of the Harbor API. Defaults to https://<registry>.
+	//+kubebuilder:validation:Optional
+	URL string `json:"url,omitempty"`
+
+	// AdminCredentialsSecretRef references a Secret in the same namespace holding the
+	// username and password of a Harbor user allowed to manage the robot accounts of the project.
+	//+kubebuilder:validation:Required
+	AdminCredentialsSecretRef BasicAuthSecretReference `json:"adminCredentialsSecretRef"`
+
+	// Project is the Harbor project the robot account is created in
+	//+kubebuilder:validation:Required
+	Project string `json:"project"`
+
+	// RobotName is the name of the robot account in the project. Defaults to the
+	// HarborRobotCredentials name.
+	//+kubebuilder:validation:Optional
+	RobotName string `json:"robotName,omitempty"`
+
+	// Permissions granted to the robot account in the project. Defaults to pulling repositories.
+	//+kubebuilder:validation:Optional
+	Permissions []HarborRobotPermission `json:"permissions,omitempty"`
+
+	// RotationInterval is how often the robot secret is rotated
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default="720h"
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// HarborRobotPermission is an action allowed on a resource of the project
+type HarborRobotPermission struct {
+	// Resource of the project, e.g. repository, artifact or tag
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=repository
+	Resource string `json:"resource,omitempty"`
+
+	// Action allowed on the resource, e.g. pull, read or list
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=pull
+	Action string `json:"action,omitempty"`
+}
+
+// HarborRobotCredentialsStatus defines the observed state of HarborRobotCredentials
+type HarborRobotCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+
+	// RobotID is the ID of the managed robot account
+	//+kubebuilder:validation:Optional
+	RobotID int64 `json:"robotId,omitempty"`
+
+	// RobotName is the full name of the managed robot account, used as registry username
+	//+kubebuilder:validation:Optional
+	RobotName string `json:"robotName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Robot",type=string,JSONPath=`.status.robotName`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HarborRobotCredentials is the Schema for the harborrobotcredentials API
+type HarborRobotCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HarborRobotCredentialsSpec   `json:"spec,omitempty"`
+	Status HarborRobotCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HarborRobotCredentialsList contains a list of HarborRobotCredentials
+type HarborRobotCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HarborRobotCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *HarborRobotCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *HarborRobotCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&HarborRobotCredentials{}, &HarborRobotCredentialsList{})
+}
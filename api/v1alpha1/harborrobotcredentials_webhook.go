@@ -0,0 +1,127 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var harborrobotcredentialslog = logf.Log.WithName("harborrobotcredentials-resource")
+
+// harborNameRegexp matches the project and robot account names accepted by Harbor
+var harborNameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+func (r *HarborRobotCredentials) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-registry-astrokube-com-v1alpha1-harborrobotcredentials,mutating=false,failurePolicy=fail,sideEffects=None,groups=registry.astrokube.com,resources=harborrobotcredentials,verbs=create;update,versions=v1alpha1,name=vharborrobotcredentials.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &HarborRobotCredentials{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *HarborRobotCredentials) ValidateCreate() error {
+	harborrobotcredentialslog.Info("validate create", "name", r.Name)
+
+	return r.validateHarborRobotCredentials(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *HarborRobotCredentials) ValidateUpdate(old runtime.Object) error {
+	harborrobotcredentialslog.Info("validate update", "name", r.Name)
+
+	return r.validateHarborRobotCredentials(old.(*HarborRobotCredentials))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *HarborRobotCredentials) ValidateDelete() error {
+	harborrobotcredentialslog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *HarborRobotCredentials) validateHarborRobotCredentials(old *HarborRobotCredentials) error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateRegistryHosts(field.NewPath("spec", "registry"), []string{r.Spec.Registry})...)
+	if r.Spec.URL != "" {
+		allErrs = append(allErrs, validateURL(field.NewPath("spec", "url"), r.Spec.URL)...)
+	}
+	if r.Spec.AdminCredentialsSecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "adminCredentialsSecretRef", "name"), ""))
+	}
+	if r.Spec.Project == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "project"), ""))
+	}
+	if r.Spec.RobotName != "" && !harborNameRegexp.MatchString(r.Spec.RobotName) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "robotName"), r.Spec.RobotName,
+			"must consist of lower case alphanumeric characters separated by '.', '_' or '-'"))
+	}
+	for i, permission := range r.Spec.Permissions {
+		if permission.Resource == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec", "permissions").Index(i).Child("resource"), ""))
+		}
+		if permission.Action == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec", "permissions").Index(i).Child("action"), ""))
+		}
+	}
+	if r.Spec.RotationInterval != nil && r.Spec.RotationInterval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "rotationInterval"), r.Spec.RotationInterval.Duration.String(), "must be positive"))
+	}
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+
+	// The robot account is only known by its ID, moving it would leak the previous one
+	if old != nil {
+		if r.Spec.Project != old.Spec.Project {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "project"), r.Spec.Project, "field is immutable"))
+		}
+		if r.Spec.RobotName != old.Spec.RobotName {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "robotName"), r.Spec.RobotName, "field is immutable"))
+		}
+		if r.harborURL() != old.harborURL() {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "url"), r.harborURL(), "the Harbor API URL is immutable"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "HarborRobotCredentials"},
+		r.Name, allErrs)
+}
+
+// harborURL returns the URL of the Harbor API
+func (r *HarborRobotCredentials) harborURL() string {
+	if r.Spec.URL != "" {
+		return r.Spec.URL
+	}
+	return "https://" + r.Spec.Registry
+}
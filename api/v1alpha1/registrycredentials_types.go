@@ -0,0 +1,125 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegistryAuthType defines how RegistryCredentials are checked against the registry
+//+kubebuilder:validation:Enum=Token;Basic
+type RegistryAuthType string
+
+const (
+	// RegistryAuthToken validates the credentials through the Docker Registry v2 authentication challenge
+	RegistryAuthToken RegistryAuthType = "Token"
+	// RegistryAuthBasic writes the credentials as static basic auth entries without contacting the registry
+	RegistryAuthBasic RegistryAuthType = "Basic"
+)
+
+// RegistryCredentialsSpec defines the desired state of RegistryCredentials
+type RegistryCredentialsSpec struct {
+	// Registry is the host of the registry, with an optional port, e.g. harbor.example.com
+	//+kubebuilder:validation:Required
+	Registry string `json:"registry"`
+
+	// CredentialsSecretRef references a Secret in the same namespace holding the
+	// username and password, or the name and secret of a robot account.
+	//+kubebuilder:validation:Optional
+	CredentialsSecretRef *BasicAuthSecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// Vault reads the username and password from a KV secret with the username and
+	// password keys. It is mutually exclusive with CredentialsSecretRef.
+	//+kubebuilder:validation:Optional
+	Vault *VaultSource `json:"vault,omitempty"`
+
+	// AuthType defines how the credentials are checked. Token validates them against the
+	// registry before writing them, Basic writes them as is.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=Token
+	AuthType RegistryAuthType `json:"authType,omitempty"`
+
+	// RegistryURL overrides the URL of the registry API. Defaults to https://<registry>.
+	//+kubebuilder:validation:Optional
+	RegistryURL string `json:"registryUrl,omitempty"`
+
+	// Scope is requested to the token service when validating the credentials,
+	// e.g. repository:library/nginx:pull. Defaults to no scope.
+	//+kubebuilder:validation:Optional
+	Scope string `json:"scope,omitempty"`
+
+	CredentialsSpec `json:",inline"`
+}
+
+// BasicAuthSecretReference selects the keys of a Secret holding a username and password
+type BasicAuthSecretReference struct {
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=username
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=password
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// RegistryCredentialsStatus defines the observed state of RegistryCredentials
+type RegistryCredentialsStatus struct {
+	CredentialsStatus `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Registry",type=string,JSONPath=`.spec.registry`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RegistryCredentials is the Schema for the registrycredentials API
+type RegistryCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RegistryCredentialsSpec   `json:"spec,omitempty"`
+	Status RegistryCredentialsStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RegistryCredentialsList contains a list of RegistryCredentials
+type RegistryCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RegistryCredentials `json:"items"`
+}
+
+// GetCredentialsSpec returns the settings shared by every credentials kind
+func (r *RegistryCredentials) GetCredentialsSpec() *CredentialsSpec {
+	return &r.Spec.CredentialsSpec
+}
+
+// GetCredentialsStatus returns the status shared by every credentials kind
+func (r *RegistryCredentials) GetCredentialsStatus() *CredentialsStatus {
+	return &r.Status.CredentialsStatus
+}
+
+func init() {
+	SchemeBuilder.Register(&RegistryCredentials{}, &RegistryCredentialsList{})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var registrycredentialslog = logf.Log.WithName("registrycredentials-resource")
+
+func (r *RegistryCredentials) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-registry-astrokube-com-v1alpha1-registrycredentials,mutating=false,failurePolicy=fail,sideEffects=None,groups=registry.astrokube.com,resources=registrycredentials,verbs=create;update,versions=v1alpha1,name=vregistrycredentials.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &RegistryCredentials{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *RegistryCredentials) ValidateCreate() error {
+	registrycredentialslog.Info("validate create", "name", r.Name)
+
+	return r.validateRegistryCredentials()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *RegistryCredentials) ValidateUpdate(old runtime.Object) error {
+	registrycredentialslog.Info("validate update", "name", r.Name)
+
+	return r.validateRegistryCredentials()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *RegistryCredentials) ValidateDelete() error {
+	registrycredentialslog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *RegistryCredentials) validateRegistryCredentials() error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateRegistryHosts(field.NewPath("spec", "registry"), []string{r.Spec.Registry})...)
+	switch {
+	case r.Spec.CredentialsSecretRef != nil && r.Spec.Vault != nil:
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "vault"),
+			"may not be set together with credentialsSecretRef"))
+	case r.Spec.Vault != nil:
+		allErrs = append(allErrs, r.Spec.Vault.validate(field.NewPath("spec", "vault"), false)...)
+	case r.Spec.CredentialsSecretRef == nil:
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "credentialsSecretRef"),
+			"credentialsSecretRef or vault must be set"))
+	case r.Spec.CredentialsSecretRef.Name == "":
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "credentialsSecretRef", "name"), ""))
+	}
+	if r.Spec.RegistryURL != "" {
+		allErrs = append(allErrs, validateURL(field.NewPath("spec", "registryUrl"), r.Spec.RegistryURL)...)
+	}
+	if r.Spec.AuthType == RegistryAuthBasic && r.Spec.Scope != "" {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "scope"), "may only be set with the Token authType"))
+	}
+	if r.Spec.SecretTemplate != nil {
+		allErrs = append(allErrs, r.Spec.SecretTemplate.validate(field.NewPath("spec", "secretTemplate"))...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "RegistryCredentials"},
+		r.Name, allErrs)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegistryCredentialsSetSpec defines the desired state of RegistryCredentialsSet
+type RegistryCredentialsSetSpec struct {
+	// Credentials are the credentials objects, in the same namespace, whose
+	// registries are merged into a single Secret. On conflicts the first
+	// credentials listed win.
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinItems=1
+	Credentials []CredentialsReference `json:"credentials"`
+
+	// SecretName is the name of the generated Secret. Defaults to the name of the RegistryCredentialsSet.
+	//+kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	ImageSelector []string `json:"imageSelector,omitempty"`
+}
+
+// CredentialsReference references a credentials object in the same namespace
+type CredentialsReference struct {
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Enum=ECRCredentials;ECRPublicCredentials;GCRCredentials;ACRCredentials;GHCRCredentials;RegistryCredentials;HarborRobotCredentials
+	Kind string `json:"kind"`
+
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// RegistryCredentialsSetStatus defines the observed state of RegistryCredentialsSet
+type RegistryCredentialsSetStatus struct {
+	//+kubebuilder:validation:Optional
+	Phase RegistryCredentialsSetPhase `json:"phase,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// RegistryHosts are the registries merged into the Secret
+	//+kubebuilder:validation:Optional
+	RegistryHosts []string `json:"registryHosts,omitempty"`
+
+	// SecretName is the name of the generated Secret
+	//+kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// ObservedGeneration is the last generation reconciled by the controller
+	//+kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the set state
+	//+kubebuilder:validation:Optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RegistryCredentialsSetPhase is a summary of the status conditions
+type RegistryCredentialsSetPhase string
+
+var (
+	RegistryCredentialsSetPending RegistryCredentialsSetPhase = "Pending"
+	RegistryCredentialsSetSynced  RegistryCredentialsSetPhase = "Synced"
+	RegistryCredentialsSetError   RegistryCredentialsSetPhase = "Error"
+)
+
+// Condition types of RegistryCredentialsSet
+const (
+	// ConditionMembersReady is true when the registries of every member are merged into the Secret
+	ConditionMembersReady = "MembersReady"
+)
+
+// Condition reasons of RegistryCredentialsSet
+const (
+	ReasonMembersReady      = "MembersReady"
+	ReasonMembersNotReady   = "MembersNotReady"
+	ReasonInvalidMembers    = "InvalidMembers"
+	ReasonUnsupportedFormat = "UnsupportedFormat"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RegistryCredentialsSet is the Schema for the registrycredentialssets API
+type RegistryCredentialsSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RegistryCredentialsSetSpec   `json:"spec,omitempty"`
+	Status RegistryCredentialsSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RegistryCredentialsSetList contains a list of RegistryCredentialsSet
+type RegistryCredentialsSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RegistryCredentialsSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RegistryCredentialsSet{}, &RegistryCredentialsSetList{})
+}
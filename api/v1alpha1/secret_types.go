@@ -0,0 +1,77 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecretTemplate defines the metadata and the additional data of the generated Secret
+type SecretTemplate struct {
+	// Name of the generated Secret. Defaults to the name of the credentials.
+	//+kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Labels added to the generated Secret
+	//+kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to the generated Secret
+	//+kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Format of the generated credentials. Defaults to dockerconfigjson.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=dockerconfigjson
+	Format SecretFormat `json:"format,omitempty"`
+
+	// Type of the generated Secret. Defaults to the type of the format.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=kubernetes.io/dockerconfigjson;Opaque
+	Type corev1.SecretType `json:"type,omitempty"`
+
+	// Data are additional keys of the generated Secret. Values are Go templates
+	// rendered with the fields .Registry, .Registries and .ExpiresAt
+	//+kubebuilder:validation:Optional
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// SecretFormat is the shape of the credentials written to the generated Secret
+//+kubebuilder:validation:Enum=dockerconfigjson;dockercfg;basic-auth;config.json;username-password
+type SecretFormat string
+
+const (
+	// SecretFormatDockerConfigJSON writes a kubernetes.io/dockerconfigjson Secret
+	SecretFormatDockerConfigJSON SecretFormat = "dockerconfigjson"
+	// SecretFormatDockerCfg writes a legacy kubernetes.io/dockercfg Secret
+	SecretFormatDockerCfg SecretFormat = "dockercfg"
+	// SecretFormatBasicAuth writes a kubernetes.io/basic-auth Secret for the first registry
+	SecretFormatBasicAuth SecretFormat = "basic-auth"
+	// SecretFormatConfigJSON writes an Opaque Secret with a config.json key, as used by Kaniko, Buildah or Podman
+	SecretFormatConfigJSON SecretFormat = "config.json"
+	// SecretFormatUsernamePassword writes an Opaque Secret with username and password keys for the first registry
+	SecretFormatUsernamePassword SecretFormat = "username-password"
+)
+
+// SecretFormatKeys are the keys generated by the controller for every format
+var SecretFormatKeys = map[SecretFormat][]string{
+	SecretFormatDockerConfigJSON: {corev1.DockerConfigJsonKey},
+	SecretFormatDockerCfg:        {corev1.DockerConfigKey},
+	SecretFormatBasicAuth:        {corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey},
+	SecretFormatConfigJSON:       {"config.json"},
+	SecretFormatUsernamePassword: {"username", "password"},
+}
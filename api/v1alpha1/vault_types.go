@@ -0,0 +1,84 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// VaultSource reads credentials from HashiCorp Vault, authenticating with the
+// Kubernetes auth method as a ServiceAccount. Exactly one of KV and AWS must be set.
+type VaultSource struct {
+	// Address is the URL of the Vault server, e.g. https://vault.example.com:8200
+	//+kubebuilder:validation:Required
+	Address string `json:"address"`
+
+	// Namespace is the Vault Enterprise namespace
+	//+kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+
+	//+kubebuilder:validation:Required
+	Auth VaultKubernetesAuth `json:"auth"`
+
+	// KV reads the credentials from a KV version 2 secret
+	//+kubebuilder:validation:Optional
+	KV *VaultKVSecret `json:"kv,omitempty"`
+
+	// AWS requests dynamic credentials to the AWS secrets engine. Only allowed for AWS authentications.
+	//+kubebuilder:validation:Optional
+	AWS *VaultAWSSecret `json:"aws,omitempty"`
+}
+
+// VaultKubernetesAuth logs in to Vault with a token of a ServiceAccount
+type VaultKubernetesAuth struct {
+	// ServiceAccountName is a ServiceAccount in the same namespace whose token is
+	// requested through the TokenRequest API to log in to Vault.
+	//+kubebuilder:validation:Required
+	ServiceAccountName string `json:"serviceAccountName"`
+
+	// Role is the Vault role bound to the ServiceAccount
+	//+kubebuilder:validation:Required
+	Role string `json:"role"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=kubernetes
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Audience of the ServiceAccount token. Tokens are never requested for the audience of the
+	// API server, as they are sent to the Vault address.
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=vault
+	Audience string `json:"audience,omitempty"`
+}
+
+// VaultKVSecret selects a KV version 2 secret
+type VaultKVSecret struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=secret
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Path of the secret in the engine, e.g. registries/harbor
+	//+kubebuilder:validation:Required
+	Path string `json:"path"`
+}
+
+// VaultAWSSecret selects a role of the AWS secrets engine
+type VaultAWSSecret struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=aws
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Role of the engine the credentials are generated for
+	//+kubebuilder:validation:Required
+	Role string `json:"role"`
+}
@@ -21,16 +21,259 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACRCredentials) DeepCopyInto(out *ACRCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACRCredentials.
+func (in *ACRCredentials) DeepCopy() *ACRCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(ACRCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ACRCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACRCredentialsList) DeepCopyInto(out *ACRCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ACRCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACRCredentialsList.
+func (in *ACRCredentialsList) DeepCopy() *ACRCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(ACRCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ACRCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACRCredentialsSpec) DeepCopyInto(out *ACRCredentialsSpec) {
+	*out = *in
+	out.ServicePrincipalSecretRef = in.ServicePrincipalSecretRef
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACRCredentialsSpec.
+func (in *ACRCredentialsSpec) DeepCopy() *ACRCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ACRCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACRCredentialsStatus) DeepCopyInto(out *ACRCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACRCredentialsStatus.
+func (in *ACRCredentialsStatus) DeepCopy() *ACRCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ACRCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSAuthentication) DeepCopyInto(out *AWSAuthentication) {
+	*out = *in
+	if in.AccessKeySecretRef != nil {
+		in, out := &in.AccessKeySecretRef, &out.AccessKeySecretRef
+		*out = new(AccessKeySecretReference)
+		**out = **in
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSAuthentication.
+func (in *AWSAuthentication) DeepCopy() *AWSAuthentication {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSAuthentication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSEndpoints) DeepCopyInto(out *AWSEndpoints) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSEndpoints.
+func (in *AWSEndpoints) DeepCopy() *AWSEndpoints {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSEndpoints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessKeySecretReference) DeepCopyInto(out *AccessKeySecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessKeySecretReference.
+func (in *AccessKeySecretReference) DeepCopy() *AccessKeySecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessKeySecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BasicAuthSecretReference) DeepCopyInto(out *BasicAuthSecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuthSecretReference.
+func (in *BasicAuthSecretReference) DeepCopy() *BasicAuthSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(BasicAuthSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialsReference) DeepCopyInto(out *CredentialsReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsReference.
+func (in *CredentialsReference) DeepCopy() *CredentialsReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialsReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialsSpec) DeepCopyInto(out *CredentialsSpec) {
+	*out = *in
+	if in.RefreshBefore != nil {
+		in, out := &in.RefreshBefore, &out.RefreshBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SecretTemplate != nil {
+		in, out := &in.SecretTemplate, &out.SecretTemplate
+		*out = new(SecretTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageSelector != nil {
+		in, out := &in.ImageSelector, &out.ImageSelector
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsSpec.
+func (in *CredentialsSpec) DeepCopy() *CredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialsStatus) DeepCopyInto(out *CredentialsStatus) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRefreshTime != nil {
+		in, out := &in.LastRefreshTime, &out.LastRefreshTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RegistryHosts != nil {
+		in, out := &in.RegistryHosts, &out.RegistryHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsStatus.
+func (in *CredentialsStatus) DeepCopy() *CredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECRCredentials) DeepCopyInto(out *ECRCredentials) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRCredentials.
@@ -86,11 +329,18 @@ func (in *ECRCredentialsList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECRCredentialsSpec) DeepCopyInto(out *ECRCredentialsSpec) {
 	*out = *in
-	if in.ImageSelector != nil {
-		in, out := &in.ImageSelector, &out.ImageSelector
-		*out = make([]string, len(*in))
+	in.AWSAuthentication.DeepCopyInto(&out.AWSAuthentication)
+	if in.RegistryIDs != nil {
+		in, out := &in.RegistryIDs, &out.RegistryIDs
+		*out = make([]RegistryID, len(*in))
 		copy(*out, *in)
 	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(AWSEndpoints)
+		**out = **in
+	}
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRCredentialsSpec.
@@ -106,6 +356,7 @@ func (in *ECRCredentialsSpec) DeepCopy() *ECRCredentialsSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ECRCredentialsStatus) DeepCopyInto(out *ECRCredentialsStatus) {
 	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRCredentialsStatus.
@@ -117,3 +368,765 @@ func (in *ECRCredentialsStatus) DeepCopy() *ECRCredentialsStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECRPublicCredentials) DeepCopyInto(out *ECRPublicCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRPublicCredentials.
+func (in *ECRPublicCredentials) DeepCopy() *ECRPublicCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(ECRPublicCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECRPublicCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECRPublicCredentialsList) DeepCopyInto(out *ECRPublicCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ECRPublicCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRPublicCredentialsList.
+func (in *ECRPublicCredentialsList) DeepCopy() *ECRPublicCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(ECRPublicCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ECRPublicCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECRPublicCredentialsSpec) DeepCopyInto(out *ECRPublicCredentialsSpec) {
+	*out = *in
+	in.AWSAuthentication.DeepCopyInto(&out.AWSAuthentication)
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRPublicCredentialsSpec.
+func (in *ECRPublicCredentialsSpec) DeepCopy() *ECRPublicCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ECRPublicCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ECRPublicCredentialsStatus) DeepCopyInto(out *ECRPublicCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ECRPublicCredentialsStatus.
+func (in *ECRPublicCredentialsStatus) DeepCopy() *ECRPublicCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ECRPublicCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCRCredentials) DeepCopyInto(out *GCRCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCRCredentials.
+func (in *GCRCredentials) DeepCopy() *GCRCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(GCRCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GCRCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCRCredentialsList) DeepCopyInto(out *GCRCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GCRCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCRCredentialsList.
+func (in *GCRCredentialsList) DeepCopy() *GCRCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(GCRCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GCRCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCRCredentialsSpec) DeepCopyInto(out *GCRCredentialsSpec) {
+	*out = *in
+	out.ServiceAccountKeySecretRef = in.ServiceAccountKeySecretRef
+	if in.Registries != nil {
+		in, out := &in.Registries, &out.Registries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCRCredentialsSpec.
+func (in *GCRCredentialsSpec) DeepCopy() *GCRCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GCRCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCRCredentialsStatus) DeepCopyInto(out *GCRCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCRCredentialsStatus.
+func (in *GCRCredentialsStatus) DeepCopy() *GCRCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GCRCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GHCRCredentials) DeepCopyInto(out *GHCRCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GHCRCredentials.
+func (in *GHCRCredentials) DeepCopy() *GHCRCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(GHCRCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GHCRCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GHCRCredentialsList) DeepCopyInto(out *GHCRCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GHCRCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GHCRCredentialsList.
+func (in *GHCRCredentialsList) DeepCopy() *GHCRCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(GHCRCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GHCRCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GHCRCredentialsSpec) DeepCopyInto(out *GHCRCredentialsSpec) {
+	*out = *in
+	out.PrivateKeySecretRef = in.PrivateKeySecretRef
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GHCRCredentialsSpec.
+func (in *GHCRCredentialsSpec) DeepCopy() *GHCRCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GHCRCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GHCRCredentialsStatus) DeepCopyInto(out *GHCRCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GHCRCredentialsStatus.
+func (in *GHCRCredentialsStatus) DeepCopy() *GHCRCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GHCRCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborRobotCredentials) DeepCopyInto(out *HarborRobotCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborRobotCredentials.
+func (in *HarborRobotCredentials) DeepCopy() *HarborRobotCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborRobotCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarborRobotCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborRobotCredentialsList) DeepCopyInto(out *HarborRobotCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HarborRobotCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborRobotCredentialsList.
+func (in *HarborRobotCredentialsList) DeepCopy() *HarborRobotCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborRobotCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarborRobotCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborRobotCredentialsSpec) DeepCopyInto(out *HarborRobotCredentialsSpec) {
+	*out = *in
+	out.AdminCredentialsSecretRef = in.AdminCredentialsSecretRef
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = make([]HarborRobotPermission, len(*in))
+		copy(*out, *in)
+	}
+	if in.RotationInterval != nil {
+		in, out := &in.RotationInterval, &out.RotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborRobotCredentialsSpec.
+func (in *HarborRobotCredentialsSpec) DeepCopy() *HarborRobotCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborRobotCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborRobotCredentialsStatus) DeepCopyInto(out *HarborRobotCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborRobotCredentialsStatus.
+func (in *HarborRobotCredentialsStatus) DeepCopy() *HarborRobotCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborRobotCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborRobotPermission) DeepCopyInto(out *HarborRobotPermission) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborRobotPermission.
+func (in *HarborRobotPermission) DeepCopy() *HarborRobotPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborRobotPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateKeySecretReference) DeepCopyInto(out *PrivateKeySecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateKeySecretReference.
+func (in *PrivateKeySecretReference) DeepCopy() *PrivateKeySecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateKeySecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentials) DeepCopyInto(out *RegistryCredentials) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentials.
+func (in *RegistryCredentials) DeepCopy() *RegistryCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistryCredentials) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsList) DeepCopyInto(out *RegistryCredentialsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RegistryCredentials, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsList.
+func (in *RegistryCredentialsList) DeepCopy() *RegistryCredentialsList {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistryCredentialsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsSet) DeepCopyInto(out *RegistryCredentialsSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsSet.
+func (in *RegistryCredentialsSet) DeepCopy() *RegistryCredentialsSet {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistryCredentialsSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsSetList) DeepCopyInto(out *RegistryCredentialsSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RegistryCredentialsSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsSetList.
+func (in *RegistryCredentialsSetList) DeepCopy() *RegistryCredentialsSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RegistryCredentialsSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsSetSpec) DeepCopyInto(out *RegistryCredentialsSetSpec) {
+	*out = *in
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = make([]CredentialsReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageSelector != nil {
+		in, out := &in.ImageSelector, &out.ImageSelector
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsSetSpec.
+func (in *RegistryCredentialsSetSpec) DeepCopy() *RegistryCredentialsSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsSetStatus) DeepCopyInto(out *RegistryCredentialsSetStatus) {
+	*out = *in
+	if in.RegistryHosts != nil {
+		in, out := &in.RegistryHosts, &out.RegistryHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsSetStatus.
+func (in *RegistryCredentialsSetStatus) DeepCopy() *RegistryCredentialsSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsSpec) DeepCopyInto(out *RegistryCredentialsSpec) {
+	*out = *in
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(BasicAuthSecretReference)
+		**out = **in
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSource)
+		(*in).DeepCopyInto(*out)
+	}
+	in.CredentialsSpec.DeepCopyInto(&out.CredentialsSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsSpec.
+func (in *RegistryCredentialsSpec) DeepCopy() *RegistryCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredentialsStatus) DeepCopyInto(out *RegistryCredentialsStatus) {
+	*out = *in
+	in.CredentialsStatus.DeepCopyInto(&out.CredentialsStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredentialsStatus.
+func (in *RegistryCredentialsStatus) DeepCopy() *RegistryCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretTemplate) DeepCopyInto(out *SecretTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplate.
+func (in *SecretTemplate) DeepCopy() *SecretTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountKeySecretReference) DeepCopyInto(out *ServiceAccountKeySecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountKeySecretReference.
+func (in *ServiceAccountKeySecretReference) DeepCopy() *ServiceAccountKeySecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountKeySecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServicePrincipalSecretReference) DeepCopyInto(out *ServicePrincipalSecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServicePrincipalSecretReference.
+func (in *ServicePrincipalSecretReference) DeepCopy() *ServicePrincipalSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ServicePrincipalSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultAWSSecret) DeepCopyInto(out *VaultAWSSecret) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultAWSSecret.
+func (in *VaultAWSSecret) DeepCopy() *VaultAWSSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultAWSSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultKVSecret) DeepCopyInto(out *VaultKVSecret) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultKVSecret.
+func (in *VaultKVSecret) DeepCopy() *VaultKVSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultKVSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultKubernetesAuth) DeepCopyInto(out *VaultKubernetesAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultKubernetesAuth.
+func (in *VaultKubernetesAuth) DeepCopy() *VaultKubernetesAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultKubernetesAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSource) DeepCopyInto(out *VaultSource) {
+	*out = *in
+	out.Auth = in.Auth
+	if in.KV != nil {
+		in, out := &in.KV, &out.KV
+		*out = new(VaultKVSecret)
+		**out = **in
+	}
+	if in.AWS != nil {
+		in, out := &in.AWS, &out.AWS
+		*out = new(VaultAWSSecret)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSource.
+func (in *VaultSource) DeepCopy() *VaultSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSource)
+	in.DeepCopyInto(out)
+	return out
+}
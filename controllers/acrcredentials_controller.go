@@ -0,0 +1,227 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	servicePrincipalSecretRefField = ".spec.servicePrincipalSecretRef.name"
+
+	// acrDefaultAuthorityURL is the Azure Active Directory endpoint of the public cloud
+	acrDefaultAuthorityURL = "https://login.microsoftonline.com"
+	// acrScope is the scope of the AAD access tokens exchanged for ACR refresh tokens
+	acrScope = "https://management.azure.com/.default"
+	// acrUsername is the username expected by ACR for refresh tokens
+	acrUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+// ACRCredentialsReconciler reconciles a ACRCredentials object
+type ACRCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// acrCredentialsKind wires ACRCredentials to their controller, indexed by
+// the Secret holding their service principal
+var acrCredentialsKind = credentialsKind{
+	name:           "acrcredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.ACRCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.ACRCredentialsList{} },
+	secretRefField: servicePrincipalSecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		return object.(*registryv1alpha1.ACRCredentials).Spec.ServicePrincipalSecretRef.Name
+	},
+}
+
+// acrServicePrincipal is the client ID and secret of an Azure service principal
+type acrServicePrincipal struct {
+	clientID     string
+	clientSecret string
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=acrcredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=acrcredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=acrcredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *ACRCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, acrCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ACRCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, acrCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider with an ACR refresh token exchanged for an AAD access token
+func (r *ACRCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	acrCredentials := object.(*registryv1alpha1.ACRCredentials)
+
+	servicePrincipal, err := r.getServicePrincipal(log, acrCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	authorityURL := acrCredentials.Spec.AuthorityURL
+	if authorityURL == "" {
+		authorityURL = acrDefaultAuthorityURL
+	}
+	aadToken, err := getAADAccessToken(authorityURL, acrCredentials.Spec.TenantID, servicePrincipal)
+	if err != nil {
+		log.Info("Unable to get AAD access token", "authorityUrl", authorityURL)
+		return nil, err
+	}
+
+	registryURL := acrCredentials.Spec.RegistryURL
+	if registryURL == "" {
+		registryURL = "https://" + acrCredentials.Spec.Registry
+	}
+	refreshToken, err := exchangeACRRefreshToken(registryURL, acrCredentials.Spec.Registry, acrCredentials.Spec.TenantID, aadToken.AccessToken)
+	if err != nil {
+		log.Info("Unable to exchange AAD access token", "registryUrl", registryURL)
+		return nil, err
+	}
+
+	// Refresh tokens are JWTs, fall back to the AAD token lifetime when their expiration is unknown
+	expiresAt, err := getJWTExpiry(refreshToken)
+	if err != nil {
+		log.Info("Unable to get refresh token expiration", "error", err.Error())
+		expiresAt = time.Now().Add(time.Duration(aadToken.ExpiresIn) * time.Second)
+	}
+
+	return &RegistryCredentials{
+		Auths: []RegistryAuth{
+			{
+				Host:               acrCredentials.Spec.Registry,
+				AuthorizationToken: base64.StdEncoding.EncodeToString([]byte(acrUsername + ":" + refreshToken)),
+			},
+		},
+		ExpiresAt: &expiresAt,
+		Identity:  servicePrincipal.clientID,
+	}, nil
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *ACRCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isHTTPUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *ACRCredentialsReconciler) IsTransient(err error) bool {
+	return isHTTPTransient(err)
+}
+
+// getServicePrincipal returns the service principal of the Secret referenced by servicePrincipalSecretRef
+func (r *ACRCredentialsReconciler) getServicePrincipal(log logr.Logger, acrCredentials *registryv1alpha1.ACRCredentials) (*acrServicePrincipal, error) {
+	ref := acrCredentials.Spec.ServicePrincipalSecretRef
+
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      ref.Name,
+		Namespace: acrCredentials.ObjectMeta.Namespace,
+	}, secret); err != nil {
+		log.Info("Unable to get service principal secret", "secret", ref.Name)
+		return nil, err
+	}
+
+	clientIDKey := ref.ClientIDKey
+	if clientIDKey == "" {
+		clientIDKey = "clientId"
+	}
+	clientSecretKey := ref.ClientSecretKey
+	if clientSecretKey == "" {
+		clientSecretKey = "clientSecret"
+	}
+
+	clientID, ok := secret.Data[clientIDKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %q", clientIDKey, ref.Name)
+	}
+	clientSecret, ok := secret.Data[clientSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %q", clientSecretKey, ref.Name)
+	}
+
+	return &acrServicePrincipal{
+		clientID:     string(clientID),
+		clientSecret: string(clientSecret),
+	}, nil
+}
+
+// getAADAccessToken returns an Azure Active Directory access token of the service principal
+// through the OAuth2 client credentials flow
+func getAADAccessToken(authorityURL, tenantID string, servicePrincipal *acrServicePrincipal) (*oauth2Token, error) {
+	tokenURL := strings.TrimSuffix(authorityURL, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+
+	token := &oauth2Token{}
+	if err := postForm(tokenURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {servicePrincipal.clientID},
+		"client_secret": {servicePrincipal.clientSecret},
+		"scope":         {acrScope},
+	}, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token returned by %s", tokenURL)
+	}
+
+	return token, nil
+}
+
+// exchangeACRRefreshToken exchanges an AAD access token for a refresh token of the registry
+func exchangeACRRefreshToken(registryURL, registry, tenantID, accessToken string) (string, error) {
+	exchangeURL := strings.TrimSuffix(registryURL, "/") + "/oauth2/exchange"
+
+	token := &oauth2Token{}
+	if err := postForm(exchangeURL, url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {registry},
+		"tenant":       {tenantID},
+		"access_token": {accessToken},
+	}, token); err != nil {
+		return "", err
+	}
+	if token.RefreshToken == "" {
+		return "", fmt.Errorf("no refresh token returned by %s", exchangeURL)
+	}
+
+	return token.RefreshToken, nil
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newACRRefreshToken returns an ACR refresh token expiring at expiresAt
+func newACRRefreshToken(expiresAt time.Time) string {
+	return "header." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, expiresAt.Unix()))) + ".signature"
+}
+
+// newACRServer returns a stand-in of both the AAD token endpoint of the tenant accepting the
+// client:secret service principal, and of the token exchange of registry returning refreshToken
+func newACRServer(tenantID, registry, refreshToken string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+tenantID+"/oauth2/v2.0/token", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		Expect(req.ParseForm()).Should(Succeed())
+		Expect(req.PostForm.Get("grant_type")).Should(Equal("client_credentials"))
+		if req.PostForm.Get("client_id") != "client" || req.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"Invalid client secret provided."}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"aad-token","token_type":"Bearer","expires_in":3599}`))
+	})
+	mux.HandleFunc("/oauth2/exchange", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		Expect(req.ParseForm()).Should(Succeed())
+		Expect(req.PostForm.Get("grant_type")).Should(Equal("access_token"))
+		Expect(req.PostForm.Get("service")).Should(Equal(registry))
+		Expect(req.PostForm.Get("access_token")).Should(Equal("aad-token"))
+		w.Write([]byte(`{"refresh_token":"` + refreshToken + `"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+var _ = Describe("ACRCredentials controller", func() {
+
+	const (
+		tenantID = "tenant"
+		registry = "myregistry.azurecr.io"
+	)
+
+	Context("When exchanging a service principal for an ACR refresh token", func() {
+		It("Should return the refresh token and its expiration", func() {
+			expiresAt := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+			refreshToken := newACRRefreshToken(expiresAt)
+			server := newACRServer(tenantID, registry, refreshToken)
+			defer server.Close()
+
+			aadToken, err := getAADAccessToken(server.URL, tenantID, &acrServicePrincipal{clientID: "client", clientSecret: "secret"})
+			Expect(err).ToNot(HaveOccurred())
+
+			token, err := exchangeACRRefreshToken(server.URL, registry, tenantID, aadToken.AccessToken)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token).Should(Equal(refreshToken))
+			Expect(getJWTExpiry(token)).Should(Equal(expiresAt))
+		})
+	})
+})
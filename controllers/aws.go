@@ -0,0 +1,261 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	accessKeySecretRefField = ".spec.accessKeySecretRef.name"
+
+	// webIdentityAudience is the audience expected by STS for ServiceAccount tokens
+	webIdentityAudience = "sts.amazonaws.com"
+	// webIdentityTokenExpiration is the lifetime in seconds of the requested ServiceAccount tokens
+	webIdentityTokenExpiration = 3600
+)
+
+// newAwsSession returns a session for awsConfig authenticated as defined in authentication, and
+// the expiration of the source credentials when they expire, i.e. the lease of Vault credentials.
+// Tokens requested with the session must not outlive them.
+func (r *CredentialsReconciler) newAwsSession(log logr.Logger, namespace string, authentication *registryv1alpha1.AWSAuthentication, awsConfig *aws.Config) (*session.Session, *time.Time, error) {
+	awsConfig = awsConfig.Copy().WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
+
+	if authentication.ServiceAccountName != "" {
+		// Exchange a ServiceAccount token for the Role credentials
+		stsSession, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		provider := stscreds.NewWebIdentityRoleProviderWithToken(
+			sts.New(stsSession),
+			authentication.RoleArn,
+			authentication.RoleSessionName,
+			&serviceAccountTokenFetcher{
+				reconciler: r,
+				namespace:  namespace,
+				name:       authentication.ServiceAccountName,
+			},
+		)
+		awsConfig.Credentials = credentials.NewCredentials(provider)
+
+		awsSession, err := session.NewSession(awsConfig)
+		return awsSession, nil, err
+	}
+
+	var expiresAt *time.Time
+	if authentication.Vault != nil {
+		// Read the AWS credentials from Vault
+		creds, leaseExpiresAt, err := r.getVaultAWSCredentials(log, namespace, authentication.Vault)
+		if err != nil {
+			return nil, nil, err
+		}
+		awsConfig.Credentials = creds
+		expiresAt = leaseExpiresAt
+	} else {
+		accessKey, err := r.getAccessKey(log, namespace, authentication)
+		if err != nil {
+			return nil, nil, err
+		}
+		awsConfig.Credentials = credentials.NewStaticCredentialsFromCreds(accessKey)
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil || authentication.RoleArn == "" {
+		return awsSession, expiresAt, err
+	}
+
+	// Assume the Role on top of the AWS Access Key
+	return awsSession.Copy(&aws.Config{
+		Credentials: stscreds.NewCredentials(awsSession, authentication.RoleArn, func(p *stscreds.AssumeRoleProvider) {
+			if authentication.ExternalID != "" {
+				p.ExternalID = aws.String(authentication.ExternalID)
+			}
+			if authentication.RoleSessionName != "" {
+				p.RoleSessionName = authentication.RoleSessionName
+			}
+			if authentication.SessionPolicy != "" {
+				p.Policy = aws.String(authentication.SessionPolicy)
+			}
+		}),
+	}), expiresAt, nil
+}
+
+// getAccessKey returns the AWS Access Key set inline in the spec or resolved
+// from the Secret referenced by accessKeySecretRef
+func (r *CredentialsReconciler) getAccessKey(log logr.Logger, namespace string, authentication *registryv1alpha1.AWSAuthentication) (credentials.Value, error) {
+	ref := authentication.AccessKeySecretRef
+	if ref == nil {
+		return credentials.Value{
+			AccessKeyID:     authentication.AccessKeyID,
+			SecretAccessKey: authentication.SecretAccessKey,
+		}, nil
+	}
+
+	if authentication.AccessKeyID != "" || authentication.SecretAccessKey != "" {
+		return credentials.Value{}, fmt.Errorf("accessKeySecretRef may not be set together with accessKeyId and secretAccessKey")
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      ref.Name,
+		Namespace: namespace,
+	}, secret); err != nil {
+		log.Info("Unable to get access key secret", "secret", ref.Name)
+		return credentials.Value{}, err
+	}
+
+	accessKeyIDKey := ref.AccessKeyIDKey
+	if accessKeyIDKey == "" {
+		accessKeyIDKey = "accessKeyId"
+	}
+	secretAccessKeyKey := ref.SecretAccessKeyKey
+	if secretAccessKeyKey == "" {
+		secretAccessKeyKey = "secretAccessKey"
+	}
+
+	accessKeyID, ok := secret.Data[accessKeyIDKey]
+	if !ok {
+		return credentials.Value{}, fmt.Errorf("key %q not found in secret %q", accessKeyIDKey, ref.Name)
+	}
+	secretAccessKey, ok := secret.Data[secretAccessKeyKey]
+	if !ok {
+		return credentials.Value{}, fmt.Errorf("key %q not found in secret %q", secretAccessKeyKey, ref.Name)
+	}
+
+	return credentials.Value{
+		AccessKeyID:     string(accessKeyID),
+		SecretAccessKey: string(secretAccessKey),
+	}, nil
+}
+
+// serviceAccountTokenFetcher implements stscreds.TokenFetcher with tokens
+// requested for a ServiceAccount through the TokenRequest API
+type serviceAccountTokenFetcher struct {
+	reconciler *CredentialsReconciler
+	namespace  string
+	name       string
+}
+
+func (f *serviceAccountTokenFetcher) FetchToken(ctx credentials.Context) ([]byte, error) {
+	token, err := f.reconciler.getServiceAccountToken(ctx, f.namespace, f.name, []string{webIdentityAudience}, webIdentityTokenExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(token), nil
+}
+
+// endpointResolver returns a resolver using the given URLs by service endpoint ID
+// and falling back to the default resolver for the rest
+func endpointResolver(urls map[string]string) endpoints.Resolver {
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url := urls[service]; url != "" {
+			return endpoints.ResolvedEndpoint{
+				URL:           url,
+				SigningRegion: region,
+			}, nil
+		}
+
+		return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	})
+}
+
+// isUnauthorized returns true if AWS rejected the provided identity. Errors
+// returned by credential providers are unwrapped to find the original cause.
+func isUnauthorized(err error) bool {
+	if _, ok := err.(*httpError); ok {
+		// Rejected Vault logins or reads
+		return isHTTPUnauthorized(err)
+	}
+
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "UnrecognizedClientException", "AccessDenied", "InvalidIdentityToken":
+		return true
+	}
+
+	if aerr.OrigErr() != nil {
+		return isUnauthorized(aerr.OrigErr())
+	}
+	return false
+}
+
+// getAwsIdentityKey returns a key identifying the AWS identity defined in authentication,
+// without exposing its secrets, to share the tokens requested with the same identity
+func (r *CredentialsReconciler) getAwsIdentityKey(log logr.Logger, namespace string, authentication *registryv1alpha1.AWSAuthentication) (string, error) {
+	var parts []string
+	if authentication.ServiceAccountName != "" {
+		// ServiceAccount tokens are only valid in their namespace
+		parts = []string{"serviceaccount", namespace, authentication.ServiceAccountName}
+	} else if authentication.Vault != nil {
+		// Vault credentials are identified by their source, not to read them for every key
+		parts = getVaultIdentityKey(namespace, authentication.Vault)
+	} else {
+		accessKey, err := r.getAccessKey(log, namespace, authentication)
+		if err != nil {
+			return "", err
+		}
+		parts = []string{"accesskey", accessKey.AccessKeyID, accessKey.SecretAccessKey}
+	}
+	parts = append(parts,
+		authentication.RoleArn,
+		authentication.ExternalID,
+		authentication.RoleSessionName,
+		authentication.SessionPolicy,
+	)
+
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isTransient returns true if the AWS error is expected to be solved by retrying,
+// e.g. throttling, server or network errors
+func isTransient(err error) bool {
+	if _, ok := err.(*httpError); ok {
+		return isHTTPTransient(err)
+	}
+	return request.IsErrorRetryable(err) || request.IsErrorThrottle(err)
+}
@@ -1,60 +1,229 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"text/template"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// defaultRefreshBefore is how long before the expiration tokens are refreshed by default
+	defaultRefreshBefore = time.Hour
+	// minRefreshInterval bounds the refresh rate of tokens expiring within the refresh window
+	minRefreshInterval = time.Minute
+	// staticRefreshInterval is how often credentials without expiration are validated again
+	staticRefreshInterval = time.Hour
+
+	// failureBaseDelay and failureMaxDelay bound the exponential backoff of failed reconciliations
+	failureBaseDelay = 5 * time.Second
+	failureMaxDelay  = 5 * time.Minute
+
+	// credentialsFinalizer releases the generated secrets before deleting credentials objects
+	credentialsFinalizer = "registry.astrokube.com/finalizer"
+	// ownerUIDLabel identifies the secrets generated for a credentials object
+	ownerUIDLabel = "registry.astrokube.com/owner-uid"
+)
+
 type CredentialsReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Recorder record.EventRecorder
-	Scheme   *runtime.Scheme
+	Clientset kubernetes.Interface
+	Log       logr.Logger
+	Recorder  record.EventRecorder
+	Scheme    *runtime.Scheme
 }
 
-func (r *CredentialsReconciler) getSecret(credentials RegistryCredentials) corev1.Secret {
-	dockerConfig := fmt.Sprintf("{\"auths\":{\"%v\":{\"auth\":\"%v\"}}}", credentials.Host, credentials.AuthorizationToken)
+// secretTemplateData are the fields available to the secretTemplate data
+type secretTemplateData struct {
+	Registry   string
+	Registries []string
+	ExpiresAt  string
+}
+
+func (r *CredentialsReconciler) getSecret(credentials RegistryCredentials, secretTemplate *registryv1alpha1.SecretTemplate) (corev1.Secret, error) {
+	format := registryv1alpha1.SecretFormatDockerConfigJSON
+	if secretTemplate != nil && secretTemplate.Format != "" {
+		format = secretTemplate.Format
+	}
 
-	return corev1.Secret{
+	secretType, secretData, err := getSecretData(credentials, format)
+	if err != nil {
+		return corev1.Secret{}, err
+	}
+
+	secret := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            credentials.Name,
 			Namespace:       credentials.Namespace,
+			Labels:          map[string]string{},
 			OwnerReferences: credentials.OwnerReferences,
 		},
-		Type: corev1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			corev1.DockerConfigJsonKey: []byte(dockerConfig),
-		},
+		Type: secretType,
+		Data: map[string][]byte{},
 	}
+
+	if secretTemplate != nil {
+		if secretTemplate.Name != "" {
+			secret.ObjectMeta.Name = secretTemplate.Name
+		}
+		for key, value := range secretTemplate.Labels {
+			secret.ObjectMeta.Labels[key] = value
+		}
+		if len(secretTemplate.Annotations) > 0 {
+			secret.ObjectMeta.Annotations = map[string]string{}
+			for key, value := range secretTemplate.Annotations {
+				secret.ObjectMeta.Annotations[key] = value
+			}
+		}
+		if secretTemplate.Type != "" {
+			secret.Type = secretTemplate.Type
+		}
+
+		data := secretTemplateData{
+			Registries: make([]string, len(credentials.Auths)),
+		}
+		for i, auth := range credentials.Auths {
+			data.Registries[i] = auth.Host
+		}
+		if len(data.Registries) > 0 {
+			data.Registry = data.Registries[0]
+		}
+		if credentials.ExpiresAt != nil {
+			data.ExpiresAt = credentials.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+
+		for key, value := range secretTemplate.Data {
+			tmpl, err := template.New(key).Parse(value)
+			if err != nil {
+				return corev1.Secret{}, fmt.Errorf("unable to parse secretTemplate data %q: %v", key, err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return corev1.Secret{}, fmt.Errorf("unable to render secretTemplate data %q: %v", key, err)
+			}
+			secret.Data[key] = buf.Bytes()
+		}
+	}
+
+	// The generated data and owner label can't be overridden by the template
+	for key, value := range secretData {
+		secret.Data[key] = value
+	}
+	for _, ownerReference := range credentials.OwnerReferences {
+		if ownerReference.Controller != nil && *ownerReference.Controller {
+			secret.ObjectMeta.Labels[ownerUIDLabel] = string(ownerReference.UID)
+		}
+	}
+
+	return secret, nil
 }
 
+// getSecretData returns the secret type and the data holding the credentials in the given format
+func getSecretData(credentials RegistryCredentials, format registryv1alpha1.SecretFormat) (corev1.SecretType, map[string][]byte, error) {
+	dockerConfig := newDockerConfig(credentials.Auths)
+
+	switch format {
+	case registryv1alpha1.SecretFormatDockerConfigJSON, registryv1alpha1.SecretFormatConfigJSON:
+		data, err := dockerConfig.marshal()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if format == registryv1alpha1.SecretFormatConfigJSON {
+			return corev1.SecretTypeOpaque, map[string][]byte{
+				"config.json": data,
+			}, nil
+		}
+		return corev1.SecretTypeDockerConfigJson, map[string][]byte{
+			corev1.DockerConfigJsonKey: data,
+		}, nil
+	case registryv1alpha1.SecretFormatDockerCfg:
+		data, err := dockerConfig.marshalDockerCfg()
+		if err != nil {
+			return "", nil, err
+		}
+
+		return corev1.SecretTypeDockercfg, map[string][]byte{
+			corev1.DockerConfigKey: data,
+		}, nil
+	case registryv1alpha1.SecretFormatBasicAuth, registryv1alpha1.SecretFormatUsernamePassword:
+		// Basic auth holds a single registry, the first one
+		if len(credentials.Auths) == 0 {
+			return "", nil, fmt.Errorf("no registry to write in the %s format", format)
+		}
+		username, password, err := credentials.Auths[0].basicAuth()
+		if err != nil {
+			return "", nil, err
+		}
+
+		secretType := corev1.SecretTypeBasicAuth
+		if format == registryv1alpha1.SecretFormatUsernamePassword {
+			secretType = corev1.SecretTypeOpaque
+		}
+		return secretType, map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte(username),
+			corev1.BasicAuthPasswordKey: []byte(password),
+		}, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown secret format %q", format)
+}
+
+// createOrUpdateSecret writes the generated secret. Existing secrets which were not
+// generated for the same owner are never updated nor recreated, a conflictError is returned instead.
 func (r *CredentialsReconciler) createOrUpdateSecret(log logr.Logger, object *corev1.Secret) error {
 	ctx := context.Background()
 
+	existing := &corev1.Secret{}
 	err := r.Client.Get(ctx, client.ObjectKey{
 		Name:      object.ObjectMeta.Name,
 		Namespace: object.ObjectMeta.Namespace,
-	}, &corev1.Secret{})
+	}, existing)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 
+	if err == nil && !isSecretOwned(existing, object) {
+		return &conflictError{fmt.Sprintf("secret %q already exists and is not managed by this object", object.ObjectMeta.Name)}
+	}
+
+	// The secret type is immutable, recreate the secret when the format changes
+	if err == nil && existing.Type != object.Type {
+		if err := r.Client.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Unable to delete object")
+			return err
+		}
+		err = errors.NewNotFound(corev1.Resource("secrets"), object.ObjectMeta.Name)
+	}
+
 	if err != nil && errors.IsNotFound(err) {
 		if err := r.Client.Create(ctx, object); err != nil {
 			log.Error(err, "Unable to create object")
 			return client.IgnoreNotFound(err)
 		}
 		r.Recorder.Eventf(object, corev1.EventTypeNormal, "Created", "Created secret %q", object.ObjectMeta.Name)
-	} else {
+	} else if !isSecretUpToDate(existing, object) {
 		if err := r.Client.Update(ctx, object); err != nil {
 			log.Error(err, "Unable to update object")
 			return client.IgnoreNotFound(err)
@@ -64,3 +233,184 @@ func (r *CredentialsReconciler) createOrUpdateSecret(log logr.Logger, object *co
 
 	return nil
 }
+
+// isSecretOwned returns true if the existing secret was generated for the controller owner
+// of the desired secret, either labeled with its UID or controlled by it
+func isSecretOwned(existing, desired *corev1.Secret) bool {
+	owner := metav1.GetControllerOf(desired)
+	if owner == nil {
+		return false
+	}
+	if existing.ObjectMeta.Labels[ownerUIDLabel] == string(owner.UID) {
+		return true
+	}
+	controller := metav1.GetControllerOf(existing)
+	return controller != nil && controller.UID == owner.UID
+}
+
+// isSecretUpToDate returns true if the existing secret already holds the data and metadata of the desired one
+func isSecretUpToDate(existing, desired *corev1.Secret) bool {
+	return existing.Type == desired.Type &&
+		equality.Semantic.DeepEqual(existing.Data, desired.Data) &&
+		equality.Semantic.DeepEqual(existing.ObjectMeta.Labels, desired.ObjectMeta.Labels) &&
+		equality.Semantic.DeepEqual(existing.ObjectMeta.Annotations, desired.ObjectMeta.Annotations) &&
+		equality.Semantic.DeepEqual(existing.ObjectMeta.OwnerReferences, desired.ObjectMeta.OwnerReferences)
+}
+
+// conflictError is returned when a resource to be managed by a credentials object
+// already exists and belongs to someone else
+type conflictError struct {
+	message string
+}
+
+func (e *conflictError) Error() string {
+	return e.message
+}
+
+// isConflict returns true if err is a conflictError
+func isConflict(err error) bool {
+	_, ok := err.(*conflictError)
+	return ok
+}
+
+// getSecretHash returns a hash of the type and the data of the secret
+func getSecretHash(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	hash.Write([]byte(secret.Type))
+	for _, key := range keys {
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write(secret.Data[key])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isSecretDrifted returns true when the secret doesn't exist or its content doesn't match the hash
+func (r *CredentialsReconciler) isSecretDrifted(namespace, name, secretHash string) (bool, error) {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(context.Background(), client.ObjectKey{Name: name, Namespace: namespace}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return getSecretHash(secret) != secretHash, nil
+}
+
+// deleteRenamedSecret deletes the secret previously generated for owner with the given name
+// once the generated secret has been renamed. Secrets not generated for owner are kept.
+func (r *CredentialsReconciler) deleteRenamedSecret(log logr.Logger, owner client.Object, previousName string, secret *corev1.Secret) error {
+	if previousName == "" || previousName == secret.ObjectMeta.Name {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	previous := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{
+		Name:      previousName,
+		Namespace: secret.ObjectMeta.Namespace,
+	}, previous); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if previous.ObjectMeta.Labels[ownerUIDLabel] != string(owner.GetUID()) {
+		return nil
+	}
+
+	if err := r.Client.Delete(ctx, previous); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Unable to delete renamed secret", "secret", previousName)
+		return err
+	}
+	r.Recorder.Eventf(owner, corev1.EventTypeNormal, "Deleted", "Deleted secret %s/%s renamed to %q", previous.ObjectMeta.Namespace, previousName, secret.ObjectMeta.Name)
+
+	return nil
+}
+
+// getServiceAccountToken requests a token for the given ServiceAccount through the TokenRequest API
+func (r *CredentialsReconciler) getServiceAccountToken(ctx context.Context, namespace, name string, audiences []string, expirationSeconds int64) (string, error) {
+	tokenRequest, err := r.Clientset.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return tokenRequest.Status.Token, nil
+}
+
+// failureRateLimiter returns the rate limiter used to retry failed authentications
+// with an exponential backoff bounded by failureMaxDelay
+func failureRateLimiter() ratelimiter.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(failureBaseDelay, failureMaxDelay)
+}
+
+// requeueBeforeExpiration returns a result requeuing the reconciliation refreshBefore
+// the expiration of the credentials. Credentials without expiration are validated
+// again after staticRefreshInterval.
+func requeueBeforeExpiration(credentials RegistryCredentials, refreshBefore time.Duration) ctrl.Result {
+	if credentials.ExpiresAt == nil {
+		return ctrl.Result{RequeueAfter: staticRefreshInterval}
+	}
+
+	requeueAfter := time.Until(credentials.ExpiresAt.Add(-refreshBefore))
+	if requeueAfter < minRefreshInterval {
+		requeueAfter = minRefreshInterval
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}
+}
+
+// cleanupSecrets releases the secrets generated for owner in every namespace. With the
+// Delete policy the secrets are deleted, with the Orphan policy they are kept but no longer owned.
+func (r *CredentialsReconciler) cleanupSecrets(log logr.Logger, owner client.Object, deletionPolicy registryv1alpha1.DeletionPolicy) error {
+	ctx := context.Background()
+
+	secrets := &corev1.SecretList{}
+	if err := r.Client.List(ctx, secrets, client.MatchingLabels{ownerUIDLabel: string(owner.GetUID())}); err != nil {
+		log.Error(err, "Unable to list secrets")
+		return err
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+
+		if deletionPolicy == registryv1alpha1.DeletionPolicyOrphan {
+			ownerReferences := []metav1.OwnerReference{}
+			for _, ownerReference := range secret.ObjectMeta.OwnerReferences {
+				if ownerReference.UID != owner.GetUID() {
+					ownerReferences = append(ownerReferences, ownerReference)
+				}
+			}
+			secret.ObjectMeta.OwnerReferences = ownerReferences
+			delete(secret.ObjectMeta.Labels, ownerUIDLabel)
+
+			if err := r.Client.Update(ctx, secret); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Unable to orphan secret", "secret", secret.ObjectMeta.Name)
+				return err
+			}
+			r.Recorder.Eventf(owner, corev1.EventTypeNormal, "Orphaned", "Orphaned secret %s/%s", secret.ObjectMeta.Namespace, secret.ObjectMeta.Name)
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Unable to delete secret", "secret", secret.ObjectMeta.Name)
+			return err
+		}
+		r.Recorder.Eventf(owner, corev1.EventTypeNormal, "Deleted", "Deleted secret %s/%s", secret.ObjectMeta.Namespace, secret.ObjectMeta.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+)
+
+// DockerConfig is the content of a kubernetes.io/dockerconfigjson Secret or a docker config.json file
+type DockerConfig struct {
+	Auths       map[string]DockerConfigEntry `json:"auths"`
+	CredHelpers map[string]string            `json:"credHelpers,omitempty"`
+}
+
+// DockerConfigEntry holds the credentials of a registry
+type DockerConfigEntry struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// newDockerConfig returns the docker config of the registries. The username and password
+// are set alongside the auth for the consumers requiring them.
+func newDockerConfig(auths []RegistryAuth) *DockerConfig {
+	dockerConfig := &DockerConfig{
+		Auths: make(map[string]DockerConfigEntry, len(auths)),
+	}
+
+	for _, auth := range auths {
+		entry := DockerConfigEntry{
+			Auth: auth.AuthorizationToken,
+		}
+		if username, password, err := auth.basicAuth(); err == nil {
+			entry.Username = username
+			entry.Password = password
+		}
+		dockerConfig.Auths[auth.Host] = entry
+	}
+
+	return dockerConfig
+}
+
+// parseDockerConfig parses the content of a docker config.json
+func parseDockerConfig(data []byte) (*DockerConfig, error) {
+	dockerConfig := &DockerConfig{}
+	if err := json.Unmarshal(data, dockerConfig); err != nil {
+		return nil, err
+	}
+
+	return dockerConfig, nil
+}
+
+// parseDockerCfg parses the content of a legacy .dockercfg, which only holds the auths
+func parseDockerCfg(data []byte) (*DockerConfig, error) {
+	dockerConfig := &DockerConfig{}
+	if err := json.Unmarshal(data, &dockerConfig.Auths); err != nil {
+		return nil, err
+	}
+
+	return dockerConfig, nil
+}
+
+// marshal returns the content of a docker config.json
+func (c *DockerConfig) marshal() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// marshalDockerCfg returns the content of a legacy .dockercfg
+func (c *DockerConfig) marshalDockerCfg() ([]byte, error) {
+	return json.Marshal(c.Auths)
+}
+
+// registryAuths returns the registries of the docker config sorted by host. Entries
+// without auth are encoded from their username and password.
+func (c *DockerConfig) registryAuths() []RegistryAuth {
+	auths := make([]RegistryAuth, 0, len(c.Auths))
+	for host, entry := range c.Auths {
+		authorizationToken := entry.Auth
+		if authorizationToken == "" && entry.Username != "" {
+			authorizationToken = base64.StdEncoding.EncodeToString([]byte(entry.Username + ":" + entry.Password))
+		}
+		auths = append(auths, RegistryAuth{Host: host, AuthorizationToken: authorizationToken})
+	}
+	sort.Slice(auths, func(i, j int) bool { return auths[i].Host < auths[j].Host })
+
+	return auths
+}
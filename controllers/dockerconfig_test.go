@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DockerConfig", func() {
+
+	Context("When serializing", func() {
+		It("Should escape hosts and tokens", func() {
+			dockerConfig := newDockerConfig([]RegistryAuth{
+				{Host: `registry"\example.com`, AuthorizationToken: "not-base64\""},
+			})
+
+			data, err := dockerConfig.marshal()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(data)).Should(Equal(`{"auths":{"registry\"\\example.com":{"auth":"not-base64\""}}}`))
+
+			parsed, err := parseDockerConfig(data)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parsed).Should(Equal(dockerConfig))
+		})
+
+		It("Should round trip the legacy dockercfg", func() {
+			dockerConfig := newDockerConfig([]RegistryAuth{
+				// AWS:password
+				{Host: "921780870478.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "QVdTOnBhc3N3b3Jk"},
+			})
+
+			data, err := dockerConfig.marshalDockerCfg()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			parsed, err := parseDockerCfg(data)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parsed.Auths).Should(Equal(dockerConfig.Auths))
+		})
+	})
+
+	Context("When parsing", func() {
+		It("Should encode the auth of entries with username and password", func() {
+			dockerConfig, err := parseDockerConfig([]byte(`{"auths":{"ghcr.io":{"username":"AWS","password":"password"}},"credHelpers":{"gcr.io":"gcloud"}}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dockerConfig.CredHelpers).Should(HaveKeyWithValue("gcr.io", "gcloud"))
+			Expect(dockerConfig.registryAuths()).Should(Equal([]RegistryAuth{
+				{Host: "ghcr.io", AuthorizationToken: "QVdTOnBhc3N3b3Jk"},
+			}))
+		})
+	})
+})
@@ -19,10 +19,11 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -33,7 +34,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ECRCredentialsReconciler reconciles a ECRCredentials object
@@ -43,6 +43,31 @@ type ECRCredentialsReconciler struct {
 	Log      logr.Logger
 	Recorder record.EventRecorder
 	Scheme   *runtime.Scheme
+
+	tokenCache *tokenCache
+}
+
+// ecrCredentialsKind wires ECRCredentials to their controller, indexed by
+// the Secret holding their AWS Access Key
+var ecrCredentialsKind = credentialsKind{
+	name:           "ecrcredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.ECRCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.ECRCredentialsList{} },
+	secretRefField: accessKeySecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		ecrCredentials := object.(*registryv1alpha1.ECRCredentials)
+		if ecrCredentials.Spec.AccessKeySecretRef == nil {
+			return ""
+		}
+		return ecrCredentials.Spec.AccessKeySecretRef.Name
+	},
+}
+
+// ecrToken is an ECR authorization token shared by the ECRCredentials with the same identity
+type ecrToken struct {
+	auths     []RegistryAuth
+	expiresAt *time.Time
+	identity  *sts.GetCallerIdentityOutput
 }
 
 //+kubebuilder:rbac:groups=registry.astrokube.com,resources=ecrcredentials,verbs=get;list;watch;create;update;patch;delete
@@ -50,157 +75,168 @@ type ECRCredentialsReconciler struct {
 //+kubebuilder:rbac:groups=registry.astrokube.com,resources=ecrcredentials/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the ECRCredentials object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
 func (r *ECRCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("ecrcredentials", req.NamespacedName)
-
-	ecrCredentials := &registryv1alpha1.ECRCredentials{}
-
-	// Skip if ecrCredentials doesn't exists
-	if err := r.Get(ctx, req.NamespacedName, ecrCredentials); err != nil {
-		if client.IgnoreNotFound(err) == nil {
-			return ctrl.Result{}, nil
-		}
-		log.Error(err, "Unable to get ECRCredentials")
-		return ctrl.Result{}, err
-	}
+	return r.reconcileKind(ctx, r.Log, req, ecrCredentialsKind, r)
+}
 
-	// ecrCredentials is not going to be deleted
-	if ecrCredentials.ObjectMeta.DeletionTimestamp.IsZero() {
+// SetupWithManager sets up the controller with the Manager.
+func (r *ECRCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.tokenCache = newTokenCache()
 
-		// If Authenticating status if is not set
-		if ecrCredentials.Status.Phase == "" {
-			if err := r.setStatus(log, ecrCredentials, registryv1alpha1.ECRCredentialsAuthenticating); err != nil {
-				return ctrl.Result{}, err
-			}
-		}
+	return r.setupKindWithManager(mgr, r.Log, ecrCredentialsKind, r)
+}
 
-		return r.authenticate(log, ecrCredentials)
-	} else {
-		// Set Terminating status
-		if err := r.setStatus(log, ecrCredentials, registryv1alpha1.ECRCredentialsTerminating); err != nil {
-			return ctrl.Result{}, err
-		}
+// Authenticate implements RegistryProvider with the ECR authorization token
+func (r *ECRCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	ecrCredentials := object.(*registryv1alpha1.ECRCredentials)
 
-		return ctrl.Result{}, nil
+	token, err := r.getCachedToken(log, ecrCredentials, force)
+	if err != nil {
+		return nil, err
 	}
+
+	return &RegistryCredentials{
+		Auths:     token.auths,
+		ExpiresAt: token.expiresAt,
+		Account:   aws.StringValue(token.identity.Account),
+		Identity:  aws.StringValue(token.identity.Arn),
+	}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *ECRCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&registryv1alpha1.ECRCredentials{}).
-		Complete(r)
+// IsUnauthorized implements RegistryProvider
+func (r *ECRCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isUnauthorized(err)
 }
 
-func (r *ECRCredentialsReconciler) authenticate(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials) (ctrl.Result, error) {
-	awsSession, err := r.getAwsSession(log, ecrCredentials)
-	if err != nil {
-		if err := r.setError(log, ecrCredentials, err); err != nil {
-			return ctrl.Result{}, err
-		}
-	}
+// IsTransient implements RegistryProvider
+func (r *ECRCredentialsReconciler) IsTransient(err error) bool {
+	return isTransient(err)
+}
 
-	credentials, err := r.getToken(log, ecrCredentials, awsSession)
-	if err != nil {
-		if err := r.setError(log, ecrCredentials, err); err != nil {
-			return ctrl.Result{}, err
-		}
+// ReportStatus implements StatusReporter with the authenticated AWS identity
+func (r *ECRCredentialsReconciler) ReportStatus(object CredentialsObject, credentials *RegistryCredentials) {
+	ecrCredentials := object.(*registryv1alpha1.ECRCredentials)
+	ecrCredentials.Status.AWSAccountID = credentials.Account
+	ecrCredentials.Status.CallerArn = credentials.Identity
+}
 
-		return ctrl.Result{}, nil
+// ecrRegistryHost returns the registry host of an AWS account using the DNS
+// suffix of the region partition, e.g. amazonaws.com.cn for cn-north-1
+func ecrRegistryHost(registryID, region string) string {
+	dnsSuffix := endpoints.AwsPartition().DNSSuffix()
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		dnsSuffix = partition.DNSSuffix()
 	}
 
-	secret := r.getSecret(*credentials)
+	return fmt.Sprintf("%s.dkr.ecr.%s.%s", registryID, region, dnsSuffix)
+}
 
-	err = r.createOrUpdateSecret(log, &secret)
-	if err != nil {
-		if err := r.setError(log, ecrCredentials, err); err != nil {
-			return ctrl.Result{}, err
+func (r *ECRCredentialsReconciler) getAwsSession(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials) (*session.Session, *time.Time, error) {
+	awsConfig := &aws.Config{
+		Region: aws.String(ecrCredentials.Spec.Region),
+	}
+	if endpoints := ecrCredentials.Spec.Endpoints; endpoints != nil {
+		urls := map[string]string{
+			ecr.EndpointsID: endpoints.ECR,
+			sts.EndpointsID: endpoints.STS,
 		}
-
-		return ctrl.Result{}, nil
+		// ServiceAccount tokens are never sent to custom STS endpoints, they could be used
+		// by whoever runs them to assume any Role trusting the ServiceAccount
+		if ecrCredentials.Spec.ServiceAccountName != "" {
+			delete(urls, sts.EndpointsID)
+		}
+		awsConfig.EndpointResolver = endpointResolver(urls)
 	}
 
-	// Set Authenticated status
-	if err := r.setStatus(log, ecrCredentials, registryv1alpha1.ECRCredentialsAuthenticated); err != nil {
-		return ctrl.Result{}, err
+	return r.newAwsSession(log, ecrCredentials.ObjectMeta.Namespace, &ecrCredentials.Spec.AWSAuthentication, awsConfig)
+}
+
+// getCallerIdentity returns the identity authenticated by the session
+func (r *ECRCredentialsReconciler) getCallerIdentity(log logr.Logger, awsSession *session.Session) (*sts.GetCallerIdentityOutput, error) {
+	stsSvc := sts.New(awsSession)
+	identity, err := stsSvc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Info("Unable to get CallerIdentity")
+		return nil, err
 	}
 
-	return ctrl.Result{}, nil
+	return identity, nil
 }
 
-func (r *ECRCredentialsReconciler) setError(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, err error) error {
-	if aerr, ok := err.(awserr.Error); ok {
-		switch aerr.Code() {
-		case "UnrecognizedClientException":
-			// Set Error status
-			if err := r.setStatus(log, ecrCredentials, registryv1alpha1.ECRCredentialsUnauthorized); err != nil {
-				return err
-			}
-		default:
-			// Set Error status
-			if err := r.setStatus(log, ecrCredentials, registryv1alpha1.ECRCredentialsError); err != nil {
-				return err
-			}
-		}
+// getCachedToken returns the token shared by the ECRCredentials with the same identity, region,
+// endpoints and registries, fetching a new one when it is about to expire or force is set
+func (r *ECRCredentialsReconciler) getCachedToken(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, force bool) (*ecrToken, error) {
+	refreshBefore := defaultRefreshBefore
+	if ecrCredentials.Spec.RefreshBefore != nil {
+		refreshBefore = ecrCredentials.Spec.RefreshBefore.Duration
 	}
 
-	// Set ErrorMessage
-	if err := r.setErrorMessage(log, ecrCredentials, err.Error()); err != nil {
-		return err
+	identityKey, err := r.getAwsIdentityKey(log, ecrCredentials.ObjectMeta.Namespace, &ecrCredentials.Spec.AWSAuthentication)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
-
-func (r *ECRCredentialsReconciler) setErrorMessage(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, message string) error {
-	ctx := context.Background()
+	keyParts := []string{identityKey, ecrCredentials.Spec.Region}
+	if ecrCredentials.Spec.Endpoints != nil {
+		keyParts = append(keyParts, ecrCredentials.Spec.Endpoints.ECR, ecrCredentials.Spec.Endpoints.STS)
+	}
+	for _, registryID := range ecrCredentials.Spec.RegistryIDs {
+		keyParts = append(keyParts, string(registryID))
+	}
 
-	ecrCredentials.Status.ErrorMessage = message
-	if err := r.Status().Update(ctx, ecrCredentials); err != nil {
-		log.Error(err, "Unable to set status")
-		return err
+	token, err := r.tokenCache.get(strings.Join(keyParts, "/"), refreshBefore, force, func() (interface{}, time.Time, error) {
+		token, err := r.fetchToken(log, ecrCredentials)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if token.expiresAt == nil {
+			return token, time.Time{}, nil
+		}
+		return token, *token.expiresAt, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return token.(*ecrToken), nil
 }
 
-func (r *ECRCredentialsReconciler) setStatus(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, phase registryv1alpha1.ECRCredentialsPhase) error {
-	ctx := context.Background()
-
-	ecrCredentials.Status.Phase = phase
-	if err := r.Status().Update(ctx, ecrCredentials); err != nil {
-		log.Error(err, "Unable to set status")
-		return err
+// fetchToken authenticates against AWS and requests a new authorization token
+func (r *ECRCredentialsReconciler) fetchToken(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials) (*ecrToken, error) {
+	awsSession, credentialsExpiresAt, err := r.getAwsSession(log, ecrCredentials)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	identity, err := r.getCallerIdentity(log, awsSession)
+	if err != nil {
+		return nil, err
+	}
 
-func (r *ECRCredentialsReconciler) getAwsSession(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials) (*session.Session, error) {
-	credentials := credentials.NewStaticCredentialsFromCreds(credentials.Value{
-		AccessKeyID:     ecrCredentials.Spec.AccessKeyID,
-		SecretAccessKey: ecrCredentials.Spec.SecretAccessKey,
-	})
-	awsConfig := &aws.Config{
-		Credentials: credentials,
-		Region:      aws.String(ecrCredentials.Spec.Region),
+	token, err := r.getToken(log, ecrCredentials, awsSession, identity)
+	if err != nil {
+		return nil, err
 	}
-	return session.NewSession(awsConfig)
+
+	// Tokens are not valid after the Vault lease of the AWS credentials expires
+	token.expiresAt = capExpiration(token.expiresAt, credentialsExpiresAt)
+
+	return token, nil
 }
 
-func (r *ECRCredentialsReconciler) getToken(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, awsSession *session.Session) (*RegistryCredentials, error) {
+func (r *ECRCredentialsReconciler) getToken(log logr.Logger, ecrCredentials *registryv1alpha1.ECRCredentials, awsSession *session.Session, identity *sts.GetCallerIdentityOutput) (*ecrToken, error) {
 	svc := ecr.New(awsSession)
 	input := &ecr.GetAuthorizationTokenInput{}
+	for _, registryID := range ecrCredentials.Spec.RegistryIDs {
+		input.RegistryIds = append(input.RegistryIds, aws.String(string(registryID)))
+	}
 
 	result, err := svc.GetAuthorizationToken(input)
 	if err != nil {
@@ -208,22 +244,46 @@ func (r *ECRCredentialsReconciler) getToken(log logr.Logger, ecrCredentials *reg
 		return nil, err
 	}
 
-	stsSvc := sts.New(awsSession)
-	identity, err := stsSvc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
-	if err != nil {
-		log.Info("Unable to get CallerIdentity")
-		return nil, err
+	if len(result.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("no authorization data returned")
 	}
 
-	host := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", *identity.Account, ecrCredentials.Spec.Region)
-	return &RegistryCredentials{
-		Name:               ecrCredentials.ObjectMeta.Name,
-		Namespace:          ecrCredentials.ObjectMeta.Namespace,
-		Host:               host,
-		AuthorizationToken: *result.AuthorizationData[0].AuthorizationToken,
-		ExpiresAt:          result.AuthorizationData[0].ExpiresAt,
-		OwnerReferences: []metav1.OwnerReference{
-			*metav1.NewControllerRef(ecrCredentials, registryv1alpha1.GroupVersion.WithKind("ECRCredentials")),
-		},
-	}, nil
+	token := &ecrToken{
+		identity: identity,
+	}
+	for i, authorizationData := range result.AuthorizationData {
+		if authorizationData.AuthorizationToken == nil {
+			continue
+		}
+
+		token.auths = append(token.auths, RegistryAuth{
+			Host:               r.getRegistryHost(ecrCredentials, identity, authorizationData, i),
+			AuthorizationToken: *authorizationData.AuthorizationToken,
+		})
+
+		// The token expires with the first expiring authorization
+		if authorizationData.ExpiresAt != nil && (token.expiresAt == nil || authorizationData.ExpiresAt.Before(*token.expiresAt)) {
+			token.expiresAt = authorizationData.ExpiresAt
+		}
+	}
+
+	if len(token.auths) == 0 {
+		return nil, fmt.Errorf("no authorization token returned")
+	}
+
+	return token, nil
+}
+
+// getRegistryHost returns the host of the ProxyEndpoint or, when ECR doesn't
+// return it, the partition host of the requested or the caller account
+func (r *ECRCredentialsReconciler) getRegistryHost(ecrCredentials *registryv1alpha1.ECRCredentials, identity *sts.GetCallerIdentityOutput, authorizationData *ecr.AuthorizationData, index int) string {
+	if authorizationData.ProxyEndpoint != nil && *authorizationData.ProxyEndpoint != "" {
+		return strings.TrimPrefix(*authorizationData.ProxyEndpoint, "https://")
+	}
+
+	if index < len(ecrCredentials.Spec.RegistryIDs) {
+		return ecrRegistryHost(string(ecrCredentials.Spec.RegistryIDs[index]), ecrCredentials.Spec.Region)
+	}
+
+	return ecrRegistryHost(aws.StringValue(identity.Account), ecrCredentials.Spec.Region)
 }
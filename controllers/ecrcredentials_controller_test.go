@@ -2,14 +2,23 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"time"
 
 	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/sts"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 var _ = Describe("EcrCredentials controller", func() {
@@ -31,15 +40,17 @@ var _ = Describe("EcrCredentials controller", func() {
 					Namespace: namespace,
 				},
 				Spec: registryv1alpha1.ECRCredentialsSpec{
-					AccessKeyID:     "test",
-					SecretAccessKey: "test",
-					Region:          "eu-central-1",
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "test",
+						SecretAccessKey: "test",
+					},
+					Region: "eu-central-1",
 				},
 			}
 			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
 
 			fetched := &registryv1alpha1.ECRCredentials{}
-			Eventually(func() registryv1alpha1.ECRCredentialsPhase {
+			Eventually(func() registryv1alpha1.CredentialsPhase {
 				k8sClient.Get(context.Background(), types.NamespacedName{
 					Name:      name,
 					Namespace: namespace,
@@ -58,15 +69,60 @@ var _ = Describe("EcrCredentials controller", func() {
 					Namespace: namespace,
 				},
 				Spec: registryv1alpha1.ECRCredentialsSpec{
-					AccessKeyID:     "test",
-					SecretAccessKey: "test",
-					Region:          "invalid",
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "test",
+						SecretAccessKey: "test",
+					},
+					Region: "invalid",
+				},
+			}
+			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
+
+			fetched := &registryv1alpha1.ECRCredentials{}
+			Eventually(func() registryv1alpha1.CredentialsPhase {
+				k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return fetched.Status.Phase
+			}, timeout, interval).Should(Equal(registryv1alpha1.ECRCredentialsUnauthorized))
+		})
+
+		It("Should set ECRCredentials.Status to Unathorized when credentials referenced from a Secret are not valid", func() {
+			By("By creating a new Secret with the AWS Access Key")
+			ctx := context.Background()
+			name := "invalid-secret-credentials"
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name + "-aws",
+					Namespace: namespace,
+				},
+				StringData: map[string]string{
+					"accessKeyId":     "test",
+					"secretAccessKey": "test",
+				},
+			}
+			Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+
+			By("By creating a new ECRCredentials referencing the Secret")
+			r := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeySecretRef: &registryv1alpha1.AccessKeySecretReference{
+							Name: name + "-aws",
+						},
+					},
+					Region: "eu-central-1",
 				},
 			}
 			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
 
 			fetched := &registryv1alpha1.ECRCredentials{}
-			Eventually(func() registryv1alpha1.ECRCredentialsPhase {
+			Eventually(func() registryv1alpha1.CredentialsPhase {
 				k8sClient.Get(context.Background(), types.NamespacedName{
 					Name:      name,
 					Namespace: namespace,
@@ -86,15 +142,17 @@ var _ = Describe("EcrCredentials controller", func() {
 						Namespace: namespace,
 					},
 					Spec: registryv1alpha1.ECRCredentialsSpec{
-						AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
-						SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
-						Region:          os.Getenv("AWS_REGION"),
+						AWSAuthentication: registryv1alpha1.AWSAuthentication{
+							AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+							SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+						},
+						Region: os.Getenv("AWS_REGION"),
 					},
 				}
 				Expect(k8sClient.Create(ctx, r)).Should(Succeed())
 
 				fetched := &registryv1alpha1.ECRCredentials{}
-				Eventually(func() registryv1alpha1.ECRCredentialsPhase {
+				Eventually(func() registryv1alpha1.CredentialsPhase {
 					k8sClient.Get(context.Background(), types.NamespacedName{
 						Name:      name,
 						Namespace: namespace,
@@ -105,4 +163,338 @@ var _ = Describe("EcrCredentials controller", func() {
 		}
 
 	})
+
+	Context("When suspending ECRCredentials", func() {
+		It("Should set ECRCredentials.Status to Suspended", func() {
+			By("By creating a new suspended ECRCredentials")
+			ctx := context.Background()
+			name := "suspended-credentials"
+			r := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "test",
+						SecretAccessKey: "test",
+					},
+					Region: "eu-central-1",
+					CredentialsSpec: registryv1alpha1.CredentialsSpec{
+						Suspend: true,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
+
+			fetched := &registryv1alpha1.ECRCredentials{}
+			Eventually(func() registryv1alpha1.CredentialsPhase {
+				k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return fetched.Status.Phase
+			}, timeout, interval).Should(Equal(registryv1alpha1.ECRCredentialsSuspended))
+		})
+	})
+
+	Context("When deleting ECRCredentials", func() {
+		It("Should release the finalizer", func() {
+			By("By creating a new ECRCredentials")
+			ctx := context.Background()
+			name := "deleted-credentials"
+			r := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "test",
+						SecretAccessKey: "test",
+					},
+					Region: "eu-central-1",
+				},
+			}
+			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
+
+			fetched := &registryv1alpha1.ECRCredentials{}
+			Eventually(func() []string {
+				k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return fetched.ObjectMeta.Finalizers
+			}, timeout, interval).Should(ContainElement(credentialsFinalizer))
+
+			By("By deleting the ECRCredentials")
+			Expect(k8sClient.Delete(ctx, fetched)).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return apierrors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When generating secrets", func() {
+		It("Should apply the secretTemplate", func() {
+			expiresAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+			controller := true
+			credentials := RegistryCredentials{
+				Name:      "credentials",
+				Namespace: namespace,
+				Auths: []RegistryAuth{
+					{Host: "921780870478.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "dG9rZW4="},
+				},
+				ExpiresAt: &expiresAt,
+				OwnerReferences: []metav1.OwnerReference{
+					{Name: "credentials", UID: "1234", Controller: &controller},
+				},
+			}
+
+			secret, err := (&CredentialsReconciler{}).getSecret(credentials, &registryv1alpha1.SecretTemplate{
+				Name:        "ecr-pull-secret",
+				Labels:      map[string]string{"app.kubernetes.io/part-of": "ci", ownerUIDLabel: "overridden"},
+				Annotations: map[string]string{"tekton.dev/docker-0": "https://{{ .Registry }}"},
+				Data: map[string]string{
+					"registry":  "{{ .Registry }}",
+					"expiresAt": "{{ .ExpiresAt }}",
+				},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(secret.ObjectMeta.Name).Should(Equal("ecr-pull-secret"))
+			Expect(secret.ObjectMeta.Labels).Should(HaveKeyWithValue("app.kubernetes.io/part-of", "ci"))
+			Expect(secret.ObjectMeta.Labels).Should(HaveKeyWithValue(ownerUIDLabel, "1234"))
+			Expect(secret.ObjectMeta.Annotations).Should(HaveKeyWithValue("tekton.dev/docker-0", "https://{{ .Registry }}"))
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeDockerConfigJson))
+			Expect(secret.Data).Should(HaveKey(corev1.DockerConfigJsonKey))
+			Expect(string(secret.Data["registry"])).Should(Equal("921780870478.dkr.ecr.eu-central-1.amazonaws.com"))
+			Expect(string(secret.Data["expiresAt"])).Should(Equal("2021-06-01T12:00:00Z"))
+		})
+	})
+
+	Context("When generating secrets in other formats", func() {
+		credentials := RegistryCredentials{
+			Name:      "credentials",
+			Namespace: namespace,
+			Auths: []RegistryAuth{
+				// AWS:password
+				{Host: "921780870478.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "QVdTOnBhc3N3b3Jk"},
+			},
+		}
+
+		It("Should write the username and password of basic-auth secrets", func() {
+			secret, err := (&CredentialsReconciler{}).getSecret(credentials, &registryv1alpha1.SecretTemplate{
+				Format: registryv1alpha1.SecretFormatBasicAuth,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeBasicAuth))
+			Expect(string(secret.Data[corev1.BasicAuthUsernameKey])).Should(Equal("AWS"))
+			Expect(string(secret.Data[corev1.BasicAuthPasswordKey])).Should(Equal("password"))
+		})
+
+		It("Should write the legacy dockercfg", func() {
+			secret, err := (&CredentialsReconciler{}).getSecret(credentials, &registryv1alpha1.SecretTemplate{
+				Format: registryv1alpha1.SecretFormatDockerCfg,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeDockercfg))
+			Expect(string(secret.Data[corev1.DockerConfigKey])).Should(Equal(`{"921780870478.dkr.ecr.eu-central-1.amazonaws.com":{"auth":"QVdTOnBhc3N3b3Jk","username":"AWS","password":"password"}}`))
+		})
+
+		It("Should write an Opaque config.json", func() {
+			secret, err := (&CredentialsReconciler{}).getSecret(credentials, &registryv1alpha1.SecretTemplate{
+				Format: registryv1alpha1.SecretFormatConfigJSON,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeOpaque))
+			Expect(string(secret.Data["config.json"])).Should(Equal(`{"auths":{"921780870478.dkr.ecr.eu-central-1.amazonaws.com":{"auth":"QVdTOnBhc3N3b3Jk","username":"AWS","password":"password"}}}`))
+		})
+	})
+
+	Context("When resolving registry hosts", func() {
+		It("Should use the DNS suffix of the region partition", func() {
+			Expect(ecrRegistryHost("921780870478", "eu-central-1")).Should(Equal("921780870478.dkr.ecr.eu-central-1.amazonaws.com"))
+			Expect(ecrRegistryHost("921780870478", "cn-north-1")).Should(Equal("921780870478.dkr.ecr.cn-north-1.amazonaws.com.cn"))
+			Expect(ecrRegistryHost("921780870478", "us-gov-west-1")).Should(Equal("921780870478.dkr.ecr.us-gov-west-1.amazonaws.com"))
+		})
+	})
+
+	Context("When assuming a role", func() {
+		It("Should assume the role with the session options on top of the access key", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.ParseForm()).Should(Succeed())
+				switch req.Form.Get("Action") {
+				case "AssumeRole":
+					Expect(req.Header.Get("Authorization")).Should(ContainSubstring("Credential=AKIABASE/"))
+					Expect(req.Form.Get("RoleArn")).Should(Equal("arn:aws:iam::123456789012:role/ecr-puller"))
+					Expect(req.Form.Get("ExternalId")).Should(Equal("external-id"))
+					Expect(req.Form.Get("RoleSessionName")).Should(Equal("registry-controller"))
+					Expect(req.Form.Get("Policy")).Should(Equal(`{"Version":"2012-10-17"}`))
+					w.Write([]byte(`<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>
+<Credentials><AccessKeyId>ASIAASSUMED</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session-token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/ecr-puller/registry-controller</Arn><AssumedRoleId>AROAEXAMPLE:registry-controller</AssumedRoleId></AssumedRoleUser>
+</AssumeRoleResult></AssumeRoleResponse>`))
+				case "GetCallerIdentity":
+					Expect(req.Header.Get("Authorization")).Should(ContainSubstring("Credential=ASIAASSUMED/"))
+					w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>
+<Arn>arn:aws:sts::123456789012:assumed-role/ecr-puller/registry-controller</Arn><UserId>AROAEXAMPLE:registry-controller</UserId><Account>123456789012</Account>
+</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+				default:
+					Fail("unexpected action " + req.Form.Get("Action"))
+				}
+			}))
+			defer server.Close()
+
+			ecrCredentials := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "cross-account", Namespace: namespace},
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					Region:    "eu-central-1",
+					Endpoints: &registryv1alpha1.AWSEndpoints{STS: server.URL},
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "AKIABASE",
+						SecretAccessKey: "secret",
+						RoleArn:         "arn:aws:iam::123456789012:role/ecr-puller",
+						ExternalID:      "external-id",
+						RoleSessionName: "registry-controller",
+						SessionPolicy:   `{"Version":"2012-10-17"}`,
+					},
+				},
+			}
+
+			r := &ECRCredentialsReconciler{Log: ctrl.Log.WithName("ecrcredentials")}
+			awsSession, expiresAt, err := r.getAwsSession(r.Log, ecrCredentials)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(expiresAt).Should(BeNil())
+
+			identity, err := r.getCallerIdentity(r.Log, awsSession)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(aws.StringValue(identity.Account)).Should(Equal("123456789012"))
+
+			By("By generating the host of the assumed account")
+			Expect(r.getRegistryHost(ecrCredentials, identity, &ecr.AuthorizationData{}, 0)).Should(Equal("123456789012.dkr.ecr.eu-central-1.amazonaws.com"))
+		})
+	})
+
+	Context("When exchanging a ServiceAccount token", func() {
+		It("Should assume the role with a token for the STS audience", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.ParseForm()).Should(Succeed())
+				switch req.Form.Get("Action") {
+				case "AssumeRoleWithWebIdentity":
+					Expect(req.Form.Get("WebIdentityToken")).Should(Equal("sa-token"))
+					Expect(req.Form.Get("RoleArn")).Should(Equal("arn:aws:iam::123456789012:role/ecr-puller"))
+					Expect(req.Form.Get("RoleSessionName")).Should(Equal("registry-controller"))
+					w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleWithWebIdentityResult>
+<Credentials><AccessKeyId>ASIAWEBIDENTITY</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session-token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/ecr-puller/registry-controller</Arn><AssumedRoleId>AROAEXAMPLE:registry-controller</AssumedRoleId></AssumedRoleUser>
+</AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+				case "GetCallerIdentity":
+					Expect(req.Header.Get("Authorization")).Should(ContainSubstring("Credential=ASIAWEBIDENTITY/"))
+					w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>
+<Arn>arn:aws:sts::123456789012:assumed-role/ecr-puller/registry-controller</Arn><UserId>AROAEXAMPLE:registry-controller</UserId><Account>123456789012</Account>
+</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+				default:
+					Fail("unexpected action " + req.Form.Get("Action"))
+				}
+			}))
+			defer server.Close()
+
+			authentication := &registryv1alpha1.AWSAuthentication{
+				ServiceAccountName: "ecr-puller",
+				RoleArn:            "arn:aws:iam::123456789012:role/ecr-puller",
+				RoleSessionName:    "registry-controller",
+			}
+			var audiences []string
+			r := &ECRCredentialsReconciler{
+				CredentialsReconciler: CredentialsReconciler{Clientset: newTokenClientset("sa-token", &audiences)},
+				Log:                   ctrl.Log.WithName("ecrcredentials"),
+			}
+			awsSession, expiresAt, err := r.newAwsSession(r.Log, namespace, authentication, &aws.Config{
+				Region:           aws.String("eu-central-1"),
+				EndpointResolver: endpointResolver(map[string]string{sts.EndpointsID: server.URL}),
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(expiresAt).Should(BeNil())
+
+			identity, err := r.getCallerIdentity(r.Log, awsSession)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(aws.StringValue(identity.Account)).Should(Equal("123456789012"))
+			Expect(audiences).Should(Equal([]string{webIdentityAudience}))
+		})
+
+		It("Should ignore custom STS endpoints of the spec", func() {
+			ecrCredentials := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "web-identity", Namespace: namespace},
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					Region:    "eu-central-1",
+					Endpoints: &registryv1alpha1.AWSEndpoints{STS: "https://sts.example.com"},
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						ServiceAccountName: "ecr-puller",
+						RoleArn:            "arn:aws:iam::123456789012:role/ecr-puller",
+					},
+				},
+			}
+
+			r := &ECRCredentialsReconciler{Log: ctrl.Log.WithName("ecrcredentials")}
+			awsSession, _, err := r.getAwsSession(r.Log, ecrCredentials)
+			Expect(err).ShouldNot(HaveOccurred())
+			endpoint, err := awsSession.Config.EndpointResolver.EndpointFor(sts.EndpointsID, "eu-central-1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(endpoint.URL).Should(HaveSuffix(".amazonaws.com"))
+		})
+	})
+
+	Context("When requesting tokens of several registries", func() {
+		It("Should generate an auth for the ProxyEndpoint of every registry", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				defer GinkgoRecover()
+				Expect(req.Header.Get("X-Amz-Target")).Should(Equal("AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"))
+				input := map[string][]string{}
+				Expect(json.NewDecoder(req.Body).Decode(&input)).Should(Succeed())
+				Expect(input["registryIds"]).Should(Equal([]string{"111111111111", "222222222222", "333333333333"}))
+
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				w.Write([]byte(`{"authorizationData":[
+{"authorizationToken":"QVdTOm9uZQ==","expiresAt":4102444800,"proxyEndpoint":"https://111111111111.dkr.ecr.eu-central-1.amazonaws.com"},
+{"authorizationToken":"QVdTOnR3bw==","expiresAt":4102441200,"proxyEndpoint":"https://222222222222.dkr.ecr.eu-central-1.amazonaws.com"},
+{"authorizationToken":"QVdTOnRocmVl","expiresAt":4102444800}
+]}`))
+			}))
+			defer server.Close()
+
+			ecrCredentials := &registryv1alpha1.ECRCredentials{
+				Spec: registryv1alpha1.ECRCredentialsSpec{
+					Region:      "eu-central-1",
+					RegistryIDs: []registryv1alpha1.RegistryID{"111111111111", "222222222222", "333333333333"},
+					Endpoints:   &registryv1alpha1.AWSEndpoints{ECR: server.URL},
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "AKIABASE",
+						SecretAccessKey: "secret",
+					},
+				},
+			}
+
+			r := &ECRCredentialsReconciler{Log: ctrl.Log.WithName("ecrcredentials")}
+			awsSession, _, err := r.getAwsSession(r.Log, ecrCredentials)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			token, err := r.getToken(r.Log, ecrCredentials, awsSession, &sts.GetCallerIdentityOutput{Account: aws.String("444444444444")})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(token.auths).Should(Equal([]RegistryAuth{
+				{Host: "111111111111.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "QVdTOm9uZQ=="},
+				{Host: "222222222222.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "QVdTOnR3bw=="},
+				{Host: "333333333333.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "QVdTOnRocmVl"},
+			}))
+
+			By("By expiring with the first expiring authorization")
+			Expect(*token.expiresAt).Should(BeTemporally("==", time.Unix(4102441200, 0)))
+		})
+	})
 })
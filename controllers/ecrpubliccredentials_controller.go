@@ -0,0 +1,138 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecrpublic"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+)
+
+const (
+	// ecrPublicRegion is the only region serving the ECR Public API
+	ecrPublicRegion = "us-east-1"
+	// ecrPublicHost is the registry host of ECR Public
+	ecrPublicHost = "public.ecr.aws"
+)
+
+// ECRPublicCredentialsReconciler reconciles a ECRPublicCredentials object
+type ECRPublicCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// ecrPublicCredentialsKind wires ECRPublicCredentials to their controller, indexed by
+// the Secret holding their AWS Access Key
+var ecrPublicCredentialsKind = credentialsKind{
+	name:           "ecrpubliccredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.ECRPublicCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.ECRPublicCredentialsList{} },
+	secretRefField: accessKeySecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		ecrPublicCredentials := object.(*registryv1alpha1.ECRPublicCredentials)
+		if ecrPublicCredentials.Spec.AccessKeySecretRef == nil {
+			return ""
+		}
+		return ecrPublicCredentials.Spec.AccessKeySecretRef.Name
+	},
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ecrpubliccredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ecrpubliccredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ecrpubliccredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *ECRPublicCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, ecrPublicCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ECRPublicCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, ecrPublicCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider with the ECR Public authorization token
+func (r *ECRPublicCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	ecrPublicCredentials := object.(*registryv1alpha1.ECRPublicCredentials)
+
+	awsSession, credentialsExpiresAt, err := r.newAwsSession(log, ecrPublicCredentials.ObjectMeta.Namespace, &ecrPublicCredentials.Spec.AWSAuthentication, &aws.Config{
+		Region: aws.String(ecrPublicRegion),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := r.getToken(log, awsSession)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens are not valid after the Vault lease of the AWS credentials expires
+	credentials.ExpiresAt = capExpiration(credentials.ExpiresAt, credentialsExpiresAt)
+
+	return credentials, nil
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *ECRPublicCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *ECRPublicCredentialsReconciler) IsTransient(err error) bool {
+	return isTransient(err)
+}
+
+func (r *ECRPublicCredentialsReconciler) getToken(log logr.Logger, awsSession *session.Session) (*RegistryCredentials, error) {
+	svc := ecrpublic.New(awsSession)
+
+	result, err := svc.GetAuthorizationToken(&ecrpublic.GetAuthorizationTokenInput{})
+	if err != nil {
+		log.Info("Unable to get authorization token")
+		return nil, err
+	}
+
+	if result.AuthorizationData == nil || result.AuthorizationData.AuthorizationToken == nil {
+		return nil, fmt.Errorf("no authorization token returned")
+	}
+
+	return &RegistryCredentials{
+		Auths: []RegistryAuth{
+			{
+				Host:               ecrPublicHost,
+				AuthorizationToken: *result.AuthorizationData.AuthorizationToken,
+			},
+		},
+		ExpiresAt: result.AuthorizationData.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("ECRPublicCredentials controller", func() {
+
+	const (
+		timeout   = time.Second * 2
+		interval  = time.Second * 1
+		namespace = "default"
+	)
+
+	Context("When creating ECRPublicCredentials", func() {
+		It("Should set ECRPublicCredentials.Status to Unathorized when credentials are not valid", func() {
+			By("By creating a new ECRPublicCredentials")
+			ctx := context.Background()
+			name := "invalid-public-credentials"
+			r := &registryv1alpha1.ECRPublicCredentials{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: registryv1alpha1.ECRPublicCredentialsSpec{
+					AWSAuthentication: registryv1alpha1.AWSAuthentication{
+						AccessKeyID:     "test",
+						SecretAccessKey: "test",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
+
+			fetched := &registryv1alpha1.ECRPublicCredentials{}
+			Eventually(func() registryv1alpha1.CredentialsPhase {
+				k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return fetched.Status.Phase
+			}, timeout, interval).Should(Equal(registryv1alpha1.ECRCredentialsUnauthorized))
+		})
+	})
+})
@@ -0,0 +1,239 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	serviceAccountKeySecretRefField = ".spec.serviceAccountKeySecretRef.name"
+
+	// gcrDefaultTokenURL is the Google OAuth2 token endpoint
+	gcrDefaultTokenURL = "https://oauth2.googleapis.com/token"
+	// gcrScope is the OAuth2 scope of the access tokens, allowing to pull from Container and Artifact Registry
+	gcrScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gcrUsername is the username expected by Google registries for OAuth2 access tokens
+	gcrUsername = "oauth2accesstoken"
+	// gcrDefaultRegistry is the registry used when none is set
+	gcrDefaultRegistry = "gcr.io"
+	// jwtBearerGrantType is the OAuth2 grant type exchanging a signed JWT for an access token
+	jwtBearerGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	// jwtAssertionLifetime is the lifetime of the JWT assertions, the maximum accepted by Google
+	jwtAssertionLifetime = time.Hour
+)
+
+// GCRCredentialsReconciler reconciles a GCRCredentials object
+type GCRCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// gcrCredentialsKind wires GCRCredentials to their controller, indexed by
+// the Secret holding their service account key
+var gcrCredentialsKind = credentialsKind{
+	name:           "gcrcredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.GCRCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.GCRCredentialsList{} },
+	secretRefField: serviceAccountKeySecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		return object.(*registryv1alpha1.GCRCredentials).Spec.ServiceAccountKeySecretRef.Name
+	},
+}
+
+// gcrServiceAccountKey is the JSON key of a Google Cloud service account
+type gcrServiceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// gcrAssertionClaims are the claims of the JWT exchanged for an access token
+type gcrAssertionClaims struct {
+	Issuer   string `json:"iss"`
+	Scope    string `json:"scope"`
+	Audience string `json:"aud"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=gcrcredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=gcrcredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=gcrcredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *GCRCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, gcrCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GCRCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, gcrCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider with an OAuth2 access token of the service account
+func (r *GCRCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	gcrCredentials := object.(*registryv1alpha1.GCRCredentials)
+
+	key, err := r.getServiceAccountKey(log, gcrCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURL := gcrCredentials.Spec.TokenURL
+	if tokenURL == "" {
+		tokenURL = key.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = gcrDefaultTokenURL
+	}
+
+	token, err := getGoogleAccessToken(key, tokenURL, time.Now())
+	if err != nil {
+		log.Info("Unable to get access token", "tokenUrl", tokenURL)
+		return nil, err
+	}
+
+	registries := gcrCredentials.Spec.Registries
+	if len(registries) == 0 {
+		registries = []string{gcrDefaultRegistry}
+	}
+
+	authorizationToken := base64.StdEncoding.EncodeToString([]byte(gcrUsername + ":" + token.AccessToken))
+	auths := make([]RegistryAuth, len(registries))
+	for i, registry := range registries {
+		auths[i] = RegistryAuth{
+			Host:               registry,
+			AuthorizationToken: authorizationToken,
+		}
+	}
+
+	expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return &RegistryCredentials{
+		Auths:     auths,
+		ExpiresAt: &expiresAt,
+		Identity:  key.ClientEmail,
+	}, nil
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *GCRCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isHTTPUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *GCRCredentialsReconciler) IsTransient(err error) bool {
+	return isHTTPTransient(err)
+}
+
+// ReportStatus implements StatusReporter with the service account email
+func (r *GCRCredentialsReconciler) ReportStatus(object CredentialsObject, credentials *RegistryCredentials) {
+	gcrCredentials := object.(*registryv1alpha1.GCRCredentials)
+	gcrCredentials.Status.ClientEmail = credentials.Identity
+}
+
+// getServiceAccountKey returns the service account key of the Secret referenced by serviceAccountKeySecretRef
+func (r *GCRCredentialsReconciler) getServiceAccountKey(log logr.Logger, gcrCredentials *registryv1alpha1.GCRCredentials) (*gcrServiceAccountKey, error) {
+	ref := gcrCredentials.Spec.ServiceAccountKeySecretRef
+	keyName := ref.Key
+	if keyName == "" {
+		keyName = "key.json"
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      ref.Name,
+		Namespace: gcrCredentials.ObjectMeta.Namespace,
+	}, secret); err != nil {
+		log.Info("Unable to get service account key secret", "secret", ref.Name)
+		return nil, err
+	}
+
+	data, ok := secret.Data[keyName]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %q", keyName, ref.Name)
+	}
+
+	key := &gcrServiceAccountKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("invalid service account key in secret %q: %v", ref.Name, err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("secret %q does not hold a service account key", ref.Name)
+	}
+
+	return key, nil
+}
+
+// getGoogleAccessToken exchanges a JWT signed with the service account key for an
+// OAuth2 access token through the JWT bearer flow
+func getGoogleAccessToken(key *gcrServiceAccountKey, tokenURL string, now time.Time) (*oauth2Token, error) {
+	privateKey, err := parseRSAPrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := signJWT(privateKey, key.PrivateKeyID, gcrAssertionClaims{
+		Issuer:   key.ClientEmail,
+		Scope:    gcrScope,
+		Audience: tokenURL,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(jwtAssertionLifetime).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oauth2Token{}
+	if err := postForm(tokenURL, url.Values{
+		"grant_type": {jwtBearerGrantType},
+		"assertion":  {assertion},
+	}, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("no access token returned by %s", tokenURL)
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = int64(jwtAssertionLifetime / time.Second)
+	}
+
+	return token, nil
+}
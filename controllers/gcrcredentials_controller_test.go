@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newServiceAccountKey returns a service account key of a new private key
+func newServiceAccountKey() (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	key, err := json.Marshal(gcrServiceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "puller@project.iam.gserviceaccount.com",
+		PrivateKeyID: "key-id",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	Expect(err).ToNot(HaveOccurred())
+	return privateKey, key
+}
+
+// newGCRTokenServer returns a stand-in of the Google token endpoint accepting assertions signed by publicKey
+func newGCRTokenServer(publicKey *rsa.PublicKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		Expect(req.ParseForm()).Should(Succeed())
+		Expect(req.PostForm.Get("grant_type")).Should(Equal(jwtBearerGrantType))
+
+		parts := strings.Split(req.PostForm.Get("assertion"), ".")
+		Expect(parts).Should(HaveLen(3))
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(err).ToNot(HaveOccurred())
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.token","token_type":"Bearer","expires_in":3599}`))
+	}))
+}
+
+var _ = Describe("GCRCredentials controller", func() {
+
+	Context("When exchanging a service account key for an access token", func() {
+		It("Should return the access token of a valid assertion", func() {
+			privateKey, data := newServiceAccountKey()
+			server := newGCRTokenServer(&privateKey.PublicKey)
+			defer server.Close()
+
+			key := &gcrServiceAccountKey{}
+			Expect(json.Unmarshal(data, key)).Should(Succeed())
+
+			token, err := getGoogleAccessToken(key, server.URL, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token.AccessToken).Should(Equal("ya29.token"))
+			Expect(token.ExpiresIn).Should(Equal(int64(3599)))
+		})
+	})
+})
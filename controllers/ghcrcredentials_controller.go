@@ -0,0 +1,212 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	privateKeySecretRefField = ".spec.privateKeySecretRef.name"
+
+	// githubDefaultAPIURL is the API URL of github.com
+	githubDefaultAPIURL = "https://api.github.com"
+	// ghcrDefaultRegistry is the GitHub Container Registry host
+	ghcrDefaultRegistry = "ghcr.io"
+	// ghcrUsername is the username written with installation tokens, which is ignored by the registry
+	ghcrUsername = "x-access-token"
+	// githubAppJWTLifetime is the lifetime of the GitHub App JWTs, the maximum accepted by GitHub is 10 minutes
+	githubAppJWTLifetime = 9 * time.Minute
+	// githubAppJWTClockSkew backdates the GitHub App JWTs to allow for clock drift
+	githubAppJWTClockSkew = time.Minute
+)
+
+// GHCRCredentialsReconciler reconciles a GHCRCredentials object
+type GHCRCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// ghcrCredentialsKind wires GHCRCredentials to their controller, indexed by
+// the Secret holding their private key
+var ghcrCredentialsKind = credentialsKind{
+	name:           "ghcrcredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.GHCRCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.GHCRCredentialsList{} },
+	secretRefField: privateKeySecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		return object.(*registryv1alpha1.GHCRCredentials).Spec.PrivateKeySecretRef.Name
+	},
+}
+
+// githubAppClaims are the claims of the JWT authenticating as a GitHub App
+type githubAppClaims struct {
+	Issuer   string `json:"iss"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+}
+
+// githubInstallationToken is an installation access token of a GitHub App
+type githubInstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ghcrcredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ghcrcredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=ghcrcredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *GHCRCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, ghcrCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GHCRCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, ghcrCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider with an installation access token of the GitHub App
+func (r *GHCRCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	ghcrCredentials := object.(*registryv1alpha1.GHCRCredentials)
+
+	privateKey, err := r.getPrivateKey(log, ghcrCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := ghcrCredentials.Spec.APIURL
+	if apiURL == "" {
+		apiURL = githubDefaultAPIURL
+	}
+	appID := strconv.FormatInt(ghcrCredentials.Spec.AppID, 10)
+
+	token, err := getGitHubInstallationToken(apiURL, appID, ghcrCredentials.Spec.InstallationID, privateKey, time.Now())
+	if err != nil {
+		log.Info("Unable to get installation token", "apiUrl", apiURL)
+		return nil, err
+	}
+
+	registry := ghcrCredentials.Spec.Registry
+	if registry == "" {
+		registry = ghcrDefaultRegistry
+	}
+
+	return &RegistryCredentials{
+		Auths: []RegistryAuth{
+			{
+				Host:               registry,
+				AuthorizationToken: base64.StdEncoding.EncodeToString([]byte(ghcrUsername + ":" + token.Token)),
+			},
+		},
+		ExpiresAt: &token.ExpiresAt,
+		Identity:  appID,
+	}, nil
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *GHCRCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isHTTPUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *GHCRCredentialsReconciler) IsTransient(err error) bool {
+	return isHTTPTransient(err)
+}
+
+// getPrivateKey returns the GitHub App private key of the Secret referenced by privateKeySecretRef
+func (r *GHCRCredentialsReconciler) getPrivateKey(log logr.Logger, ghcrCredentials *registryv1alpha1.GHCRCredentials) ([]byte, error) {
+	ref := ghcrCredentials.Spec.PrivateKeySecretRef
+	keyName := ref.Key
+	if keyName == "" {
+		keyName = "private-key.pem"
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      ref.Name,
+		Namespace: ghcrCredentials.ObjectMeta.Namespace,
+	}, secret); err != nil {
+		log.Info("Unable to get private key secret", "secret", ref.Name)
+		return nil, err
+	}
+
+	privateKey, ok := secret.Data[keyName]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %q", keyName, ref.Name)
+	}
+
+	return privateKey, nil
+}
+
+// getGitHubInstallationToken authenticates as the GitHub App with a JWT signed by its
+// private key and creates an access token of the installation
+func getGitHubInstallationToken(apiURL, appID string, installationID int64, privateKeyPEM []byte, now time.Time) (*githubInstallationToken, error) {
+	privateKey, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	jwt, err := signJWT(privateKey, "", githubAppClaims{
+		Issuer:   appID,
+		IssuedAt: now.Add(-githubAppJWTClockSkew).Unix(),
+		Expiry:   now.Add(githubAppJWTLifetime).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(apiURL, "/"), installationID)
+	req, err := http.NewRequest(http.MethodPost, tokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	token := &githubInstallationToken{}
+	if err := doJSON(req, token); err != nil {
+		return nil, err
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("no installation token returned by %s", tokenURL)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newGitHubAppPrivateKey returns a new private key of a GitHub App, and its PEM encoding
+func newGitHubAppPrivateKey() (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).ToNot(HaveOccurred())
+	return privateKey, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+}
+
+// newGitHubServer returns a stand-in of the GitHub API accepting JWTs of app 42 signed by
+// publicKey, and returning installation tokens of installation 7 expiring at expiresAt
+func newGitHubServer(publicKey *rsa.PublicKey, expiresAt time.Time) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations/7/access_tokens", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		Expect(req.Method).Should(Equal(http.MethodPost))
+
+		parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+		Expect(parts).Should(HaveLen(3))
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(err).ToNot(HaveOccurred())
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(err).ToNot(HaveOccurred())
+		claims := githubAppClaims{}
+		Expect(json.Unmarshal(payload, &claims)).Should(Succeed())
+
+		if claims.Issuer != "42" || rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"A JSON web token could not be decoded"}`))
+			return
+		}
+		Expect(claims.Expiry - claims.IssuedAt).Should(BeNumerically("<=", 600))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token":"ghs_token","expires_at":"` + expiresAt.Format(time.RFC3339) + `"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+var _ = Describe("GHCRCredentials controller", func() {
+
+	Context("When exchanging a GitHub App private key for an installation token", func() {
+		It("Should return the installation token and its expiration", func() {
+			privateKey, privateKeyPEM := newGitHubAppPrivateKey()
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			server := newGitHubServer(&privateKey.PublicKey, expiresAt)
+			defer server.Close()
+
+			token, err := getGitHubInstallationToken(server.URL, "42", 7, privateKeyPEM, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(token.Token).Should(Equal("ghs_token"))
+			Expect(token.ExpiresAt.Equal(expiresAt)).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,478 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	adminCredentialsSecretRefField = ".spec.adminCredentialsSecretRef.name"
+
+	// defaultRotationInterval is how often robot secrets are rotated when not set in the spec
+	defaultRotationInterval = 30 * 24 * time.Hour
+	// harborRobotDescription marks the robot accounts managed by the controller, followed by the owner UID
+	harborRobotDescription = "Managed by registry-controller for "
+)
+
+// HarborRobotCredentialsReconciler reconciles a HarborRobotCredentials object
+type HarborRobotCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// harborRobotCredentialsKind wires HarborRobotCredentials to their controller, indexed by
+// the Secret holding their admin credentials
+var harborRobotCredentialsKind = credentialsKind{
+	name:           "harborrobotcredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.HarborRobotCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.HarborRobotCredentialsList{} },
+	secretRefField: adminCredentialsSecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		return object.(*registryv1alpha1.HarborRobotCredentials).Spec.AdminCredentialsSecretRef.Name
+	},
+}
+
+// harborRobot is a robot account of the Harbor v2.0 API
+type harborRobot struct {
+	ID          int64                   `json:"id,omitempty"`
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	Secret      string                  `json:"secret,omitempty"`
+	Level       string                  `json:"level"`
+	Duration    int64                   `json:"duration"`
+	Disable     bool                    `json:"disable"`
+	Permissions []harborRobotPermission `json:"permissions"`
+}
+
+// harborRobotPermission grants access to the resources of a project
+type harborRobotPermission struct {
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace"`
+	Access    []harborAccess `json:"access"`
+}
+
+type harborAccess struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// harborClient calls the Harbor v2.0 API with basic auth
+type harborClient struct {
+	url      string
+	username string
+	password string
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=harborrobotcredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=harborrobotcredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=harborrobotcredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *HarborRobotCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, harborRobotCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HarborRobotCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, harborRobotCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider creating the robot account, or updating its
+// permissions when it already exists. The robot secret is only rotated when it is due, when
+// forced or when the permissions change, otherwise it is read back from the generated Secret.
+func (r *HarborRobotCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	harborRobotCredentials := object.(*registryv1alpha1.HarborRobotCredentials)
+	status := &harborRobotCredentials.Status
+
+	c, err := r.getHarborClient(log, harborRobotCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secrets that can't be read back are rotated
+	current := ""
+	if !force && status.RobotID != 0 && !isRotationDue(harborRobotCredentials) {
+		if current, err = r.getRobotSecret(harborRobotCredentials); err != nil {
+			return nil, err
+		}
+	}
+
+	robot, rotated, err := c.ensureRobot(status.RobotID, getHarborRobot(harborRobotCredentials), current == "")
+	if err != nil {
+		log.Info("Unable to rotate robot account", "url", c.url, "project", harborRobotCredentials.Spec.Project)
+		return nil, err
+	}
+
+	// Record the robot account right away, so that it is deleted with the
+	// HarborRobotCredentials even if writing its Secret fails
+	status.RobotID = robot.ID
+	status.RobotName = robot.Name
+
+	rotationInterval := defaultRotationInterval
+	if harborRobotCredentials.Spec.RotationInterval != nil {
+		rotationInterval = harborRobotCredentials.Spec.RotationInterval.Duration
+	}
+	expiresAt := time.Now().Add(rotationInterval)
+	if !rotated {
+		robot.Secret = current
+		expiresAt = status.ExpiresAt.Time
+	}
+
+	return &RegistryCredentials{
+		Auths: []RegistryAuth{
+			{
+				Host:               harborRobotCredentials.Spec.Registry,
+				AuthorizationToken: base64.StdEncoding.EncodeToString([]byte(robot.Name + ":" + robot.Secret)),
+			},
+		},
+		ExpiresAt: &expiresAt,
+		Identity:  robot.Name,
+		Rotated:   rotated,
+	}, nil
+}
+
+// isRotationDue returns true when the robot secret has to be rotated, i.e. within the
+// refresh window before the end of the rotation interval
+func isRotationDue(harborRobotCredentials *registryv1alpha1.HarborRobotCredentials) bool {
+	status := &harborRobotCredentials.Status.CredentialsStatus
+	if status.ExpiresAt == nil {
+		return true
+	}
+
+	refreshBefore := defaultRefreshBefore
+	if harborRobotCredentials.Spec.RefreshBefore != nil {
+		refreshBefore = harborRobotCredentials.Spec.RefreshBefore.Duration
+	}
+	return !time.Now().Before(status.ExpiresAt.Add(-statusRefreshBefore(status, refreshBefore)))
+}
+
+// getRobotSecret returns the secret of the robot account held by the generated Secret,
+// empty when it is not found
+func (r *HarborRobotCredentialsReconciler) getRobotSecret(harborRobotCredentials *registryv1alpha1.HarborRobotCredentials) (string, error) {
+	status := &harborRobotCredentials.Status
+	if status.SecretName == "" {
+		return "", nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      status.SecretName,
+		Namespace: harborRobotCredentials.ObjectMeta.Namespace,
+	}, secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	username, password, ok := getSecretBasicAuth(secret, harborRobotCredentials.Spec.Registry)
+	if !ok || username != status.RobotName {
+		return "", nil
+	}
+	return password, nil
+}
+
+// getSecretBasicAuth returns the username and password of host in a Secret generated in any format
+func getSecretBasicAuth(secret *corev1.Secret, host string) (string, string, bool) {
+	if username, ok := secret.Data[corev1.BasicAuthUsernameKey]; ok {
+		return string(username), string(secret.Data[corev1.BasicAuthPasswordKey]), true
+	}
+
+	var auths []RegistryAuth
+	if data, ok := secret.Data["config.json"]; ok {
+		dockerConfig, err := parseDockerConfig(data)
+		if err != nil {
+			return "", "", false
+		}
+		auths = dockerConfig.registryAuths()
+	} else {
+		var err error
+		if auths, err = getSecretRegistryAuths(secret); err != nil {
+			return "", "", false
+		}
+	}
+
+	for _, auth := range auths {
+		if auth.Host == host {
+			username, password, err := auth.basicAuth()
+			return username, password, err == nil
+		}
+	}
+	return "", "", false
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *HarborRobotCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isHTTPUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *HarborRobotCredentialsReconciler) IsTransient(err error) bool {
+	return isHTTPTransient(err)
+}
+
+// Cleanup implements CleanupProvider deleting the robot account, unless the generated
+// secrets are orphaned. Robot accounts whose admin credentials are gone are kept.
+func (r *HarborRobotCredentialsReconciler) Cleanup(log logr.Logger, object CredentialsObject, deletionPolicy registryv1alpha1.DeletionPolicy) error {
+	harborRobotCredentials := object.(*registryv1alpha1.HarborRobotCredentials)
+	robotID := harborRobotCredentials.Status.RobotID
+	robotName := harborRobotCredentials.Status.RobotName
+	if robotID == 0 || deletionPolicy == registryv1alpha1.DeletionPolicyOrphan {
+		return nil
+	}
+
+	c, err := r.getHarborClient(log, harborRobotCredentials)
+	if apierrors.IsNotFound(err) {
+		r.Recorder.Eventf(object, corev1.EventTypeWarning, "RobotOrphaned", "Kept robot account %q, the admin credentials secret was not found", robotName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.deleteRobot(robotID); err != nil {
+		log.Info("Unable to delete robot account", "robot", robotName)
+		return err
+	}
+	r.Recorder.Eventf(object, corev1.EventTypeNormal, "RobotDeleted", "Deleted robot account %q", robotName)
+
+	return nil
+}
+
+// getHarborClient returns a client of the Harbor API authenticated with the admin credentials
+func (r *HarborRobotCredentialsReconciler) getHarborClient(log logr.Logger, harborRobotCredentials *registryv1alpha1.HarborRobotCredentials) (*harborClient, error) {
+	username, password, err := r.getBasicAuth(log, harborRobotCredentials.ObjectMeta.Namespace, harborRobotCredentials.Spec.AdminCredentialsSecretRef)
+	if err != nil {
+		return nil, err
+	}
+
+	harborURL := harborRobotCredentials.Spec.URL
+	if harborURL == "" {
+		harborURL = "https://" + harborRobotCredentials.Spec.Registry
+	}
+
+	return &harborClient{
+		url:      strings.TrimSuffix(harborURL, "/"),
+		username: username,
+		password: password,
+	}, nil
+}
+
+// getHarborRobot returns the robot account defined in the spec
+func getHarborRobot(harborRobotCredentials *registryv1alpha1.HarborRobotCredentials) *harborRobot {
+	name := harborRobotCredentials.Spec.RobotName
+	if name == "" {
+		name = harborRobotCredentials.ObjectMeta.Name
+	}
+
+	access := []harborAccess{}
+	for _, permission := range harborRobotCredentials.Spec.Permissions {
+		access = append(access, harborAccess{
+			Resource: permission.Resource,
+			Action:   permission.Action,
+		})
+	}
+	if len(access) == 0 {
+		access = append(access, harborAccess{Resource: "repository", Action: "pull"})
+	}
+
+	return &harborRobot{
+		Name:        name,
+		Description: harborRobotDescription + string(harborRobotCredentials.ObjectMeta.UID),
+		Level:       "project",
+		// The robot account never expires, its secret is rotated instead
+		Duration: -1,
+		Permissions: []harborRobotPermission{
+			{
+				Kind:      "project",
+				Namespace: harborRobotCredentials.Spec.Project,
+				Access:    access,
+			},
+		},
+	}
+}
+
+// ensureRobot returns the robot account and whether its secret was rotated. The robot account
+// of robotID is updated with the desired permissions, and created again if it was deleted from
+// Harbor. Its secret is rotated when rotate is set or the permissions change, otherwise the secret
+// of the returned robot account is empty. Existing robot accounts are only adopted when their
+// description names the same owner.
+func (c *harborClient) ensureRobot(robotID int64, desired *harborRobot, rotate bool) (*harborRobot, bool, error) {
+	if robotID == 0 {
+		robot, err := c.createRobot(desired)
+		if !isHTTPStatus(err, http.StatusConflict) {
+			return robot, err == nil, err
+		}
+
+		// The robot account was created but not recorded, e.g. when the status update failed
+		existing, err := c.findRobot(desired.Permissions[0].Namespace, desired.Name)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil || existing.Description != desired.Description {
+			return nil, false, &conflictError{fmt.Sprintf("robot account %q already exists in project %q and is not managed by this object", desired.Name, desired.Permissions[0].Namespace)}
+		}
+		robotID = existing.ID
+		rotate = true
+	}
+
+	robot := &harborRobot{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/robots/%d", robotID), nil, robot); err != nil {
+		if isHTTPStatus(err, http.StatusNotFound) {
+			// The robot account was deleted from Harbor
+			robot, err := c.createRobot(desired)
+			return robot, err == nil, err
+		}
+		return nil, false, err
+	}
+
+	// Robot accounts granted new permissions get a new secret
+	if !equality.Semantic.DeepEqual(robot.Permissions, desired.Permissions) {
+		rotate = true
+	}
+	robot.Description = desired.Description
+	robot.Disable = false
+	robot.Permissions = desired.Permissions
+	if err := c.do(http.MethodPut, fmt.Sprintf("/robots/%d", robotID), robot, nil); err != nil {
+		return nil, false, err
+	}
+	robot.Secret = ""
+	if !rotate {
+		return robot, false, nil
+	}
+
+	// An empty secret makes Harbor generate a new one
+	sec := &struct {
+		Secret string `json:"secret"`
+	}{}
+	if err := c.do(http.MethodPatch, fmt.Sprintf("/robots/%d", robotID), sec, sec); err != nil {
+		return nil, false, err
+	}
+	if sec.Secret == "" {
+		return nil, false, fmt.Errorf("no secret returned for robot account %q", robot.Name)
+	}
+	robot.Secret = sec.Secret
+
+	return robot, true, nil
+}
+
+// createRobot creates the robot account, returning its full name and secret
+func (c *harborClient) createRobot(desired *harborRobot) (*harborRobot, error) {
+	robot := &harborRobot{}
+	if err := c.do(http.MethodPost, "/robots", desired, robot); err != nil {
+		return nil, err
+	}
+	if robot.ID == 0 || robot.Secret == "" {
+		return nil, fmt.Errorf("no robot account returned by %s", c.url)
+	}
+
+	return robot, nil
+}
+
+// findRobot returns the robot account of the project with the given short name, if any
+func (c *harborClient) findRobot(project, name string) (*harborRobot, error) {
+	query := url.Values{
+		"q":         []string{"name=~" + name},
+		"page_size": []string{"100"},
+	}
+	robots := []harborRobot{}
+	if err := c.do(http.MethodGet, "/robots?"+query.Encode(), nil, &robots); err != nil {
+		return nil, err
+	}
+
+	for i := range robots {
+		if robots[i].Level == "project" && isHarborRobotName(robots[i].Name, project, name) {
+			return &robots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// deleteRobot deletes the robot account, ignoring already deleted ones
+func (c *harborClient) deleteRobot(robotID int64) error {
+	err := c.do(http.MethodDelete, fmt.Sprintf("/robots/%d", robotID), nil, nil)
+	if isHTTPStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// do sends a request to the Harbor API path and decodes its response into out
+func (c *harborClient) do(method, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.url+"/api/v2.0"+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return doJSON(req, out)
+}
+
+// isHarborRobotName returns true if fullName is the name of the robot account of the
+// project, e.g. robot$library+puller, whatever the robot name prefix of the instance
+func isHarborRobotName(fullName, project, name string) bool {
+	prefix := strings.TrimSuffix(fullName, project+"+"+name)
+	if prefix == fullName {
+		return false
+	}
+	return prefix == "" || !harborNameCharacter(prefix[len(prefix)-1])
+}
+
+// harborNameCharacter returns true for the characters allowed in Harbor project names
+func harborNameCharacter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'
+}
+
+// isHTTPStatus returns true if err is an httpError with the given status code
+func isHTTPStatus(err error, statusCode int) bool {
+	herr, ok := err.(*httpError)
+	return ok && herr.StatusCode == statusCode
+}
@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// harborServer is a stand-in of the Harbor robot accounts API accepting the admin:Harbor12345 user
+type harborServer struct {
+	*httptest.Server
+	sync.Mutex
+	robots map[int64]*harborRobot
+	nextID int64
+}
+
+// newHarborServer returns a harborServer without robot accounts
+func newHarborServer() *harborServer {
+	server := &harborServer{robots: map[int64]*harborRobot{}, nextID: 1}
+	mux := http.NewServeMux()
+	authorized := func(w http.ResponseWriter, req *http.Request) bool {
+		if username, password, ok := req.BasicAuth(); !ok || username != "admin" || password != "Harbor12345" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"unauthorized"}]}`))
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/api/v2.0/robots", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		if !authorized(w, req) {
+			return
+		}
+		server.Lock()
+		defer server.Unlock()
+
+		if req.Method == http.MethodGet {
+			robots := []harborRobot{}
+			for _, robot := range server.robots {
+				if strings.Contains(robot.Name, strings.TrimPrefix(req.URL.Query().Get("q"), "name=~")) {
+					robots = append(robots, *robot)
+				}
+			}
+			json.NewEncoder(w).Encode(robots)
+			return
+		}
+
+		Expect(req.Method).Should(Equal(http.MethodPost))
+		Expect(req.Header.Get("Content-Type")).Should(Equal("application/json"))
+		robot := &harborRobot{}
+		Expect(json.NewDecoder(req.Body).Decode(robot)).Should(Succeed())
+		Expect(robot.Level).Should(Equal("project"))
+		Expect(robot.Permissions).Should(HaveLen(1))
+		name := "robot$" + robot.Permissions[0].Namespace + "+" + robot.Name
+		for _, existing := range server.robots {
+			if existing.Name == name {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"errors":[{"code":"CONFLICT","message":"robot account already exists"}]}`))
+				return
+			}
+		}
+		robot.ID = server.nextID
+		robot.Name = name
+		robot.Secret = fmt.Sprintf("secret-%d", server.nextID)
+		server.nextID++
+		server.robots[robot.ID] = robot
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(robot)
+	})
+	mux.HandleFunc("/api/v2.0/robots/", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		if !authorized(w, req) {
+			return
+		}
+		server.Lock()
+		defer server.Unlock()
+
+		var id int64
+		fmt.Sscanf(strings.TrimPrefix(req.URL.Path, "/api/v2.0/robots/"), "%d", &id)
+		robot, ok := server.robots[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"code":"NOT_FOUND","message":"robot not found"}]}`))
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(&harborRobot{ID: robot.ID, Name: robot.Name, Level: robot.Level, Duration: robot.Duration, Permissions: robot.Permissions})
+		case http.MethodPut:
+			update := &harborRobot{}
+			Expect(json.NewDecoder(req.Body).Decode(update)).Should(Succeed())
+			Expect(update.Name).Should(Equal(robot.Name))
+			robot.Permissions = update.Permissions
+		case http.MethodPatch:
+			robot.Secret = fmt.Sprintf("secret-%d-rotated", id)
+			w.Write([]byte(`{"secret":"` + robot.Secret + `"}`))
+		case http.MethodDelete:
+			delete(server.robots, id)
+		}
+	})
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+var _ = Describe("HarborRobotCredentials controller", func() {
+
+	const namespace = "default"
+
+	desired := func(name string, uid types.UID) *harborRobot {
+		return getHarborRobot(&registryv1alpha1.HarborRobotCredentials{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: uid},
+			Spec: registryv1alpha1.HarborRobotCredentialsSpec{
+				Project: "library",
+			},
+		})
+	}
+
+	Context("When managing a robot account through the Harbor API", func() {
+		It("Should create the robot account and rotate its secret", func() {
+			server := newHarborServer()
+			defer server.Close()
+			c := &harborClient{url: server.URL, username: "admin", password: "Harbor12345"}
+
+			robot, rotated, err := c.ensureRobot(0, desired("puller", "owner-uid"), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeTrue())
+			Expect(robot.ID).Should(Equal(int64(1)))
+			Expect(robot.Name).Should(Equal("robot$library+puller"))
+			Expect(robot.Secret).Should(Equal("secret-1"))
+			Expect(server.robots[1].Permissions[0].Access).Should(Equal([]harborAccess{{Resource: "repository", Action: "pull"}}))
+
+			By("By keeping the secret when the rotation is not due")
+			robot, rotated, err = c.ensureRobot(1, desired("puller", "owner-uid"), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeFalse())
+			Expect(robot.Secret).Should(BeEmpty())
+			Expect(server.robots[1].Secret).Should(Equal("secret-1"))
+
+			robot, rotated, err = c.ensureRobot(1, desired("puller", "owner-uid"), true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeTrue())
+			Expect(robot.Name).Should(Equal("robot$library+puller"))
+			Expect(robot.Secret).Should(Equal("secret-1-rotated"))
+
+			By("By rotating the secret when the permissions change")
+			server.robots[1].Secret = "secret-1"
+			pusher := desired("puller", "owner-uid")
+			pusher.Permissions[0].Access = append(pusher.Permissions[0].Access, harborAccess{Resource: "repository", Action: "push"})
+			robot, rotated, err = c.ensureRobot(1, pusher, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeTrue())
+			Expect(robot.Secret).Should(Equal("secret-1-rotated"))
+			Expect(server.robots[1].Permissions[0].Access).Should(HaveLen(2))
+
+			By("By recovering robot accounts of the same owner which were not recorded")
+			robot, rotated, err = c.ensureRobot(0, desired("puller", "owner-uid"), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeTrue())
+			Expect(robot.ID).Should(Equal(int64(1)))
+			Expect(server.robots[1].Description).Should(Equal(harborRobotDescription + "owner-uid"))
+
+			By("By refusing to adopt robot accounts of another owner")
+			_, _, err = c.ensureRobot(0, desired("puller", "other-uid"), false)
+			Expect(err).To(HaveOccurred())
+			Expect(isConflict(err)).Should(BeTrue())
+			Expect(server.robots[1].Secret).Should(Equal("secret-1-rotated"))
+
+			By("By creating again robot accounts deleted from Harbor")
+			Expect(c.deleteRobot(1)).Should(Succeed())
+			Expect(c.deleteRobot(1)).Should(Succeed())
+			robot, rotated, err = c.ensureRobot(1, desired("puller", "owner-uid"), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).Should(BeTrue())
+			Expect(robot.ID).Should(Equal(int64(2)))
+		})
+
+		It("Should read back the secret from the generated Secret until the rotation is due", func() {
+			server := newHarborServer()
+			defer server.Close()
+			server.robots[1] = &harborRobot{ID: 1, Name: "robot$library+puller", Level: "project", Secret: "secret-1", Permissions: desired("puller", "owner-uid").Permissions}
+			server.nextID = 2
+
+			expiresAt := metav1.NewTime(time.Now().Add(24 * time.Hour))
+			harborRobotCredentials := &registryv1alpha1.HarborRobotCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "puller", Namespace: namespace, UID: "owner-uid"},
+				Spec: registryv1alpha1.HarborRobotCredentialsSpec{
+					Registry: "harbor.example.com",
+					URL:      server.URL,
+					AdminCredentialsSecretRef: registryv1alpha1.BasicAuthSecretReference{
+						Name: "harbor-admin",
+					},
+					Project: "library",
+				},
+				Status: registryv1alpha1.HarborRobotCredentialsStatus{
+					CredentialsStatus: registryv1alpha1.CredentialsStatus{SecretName: "puller", ExpiresAt: &expiresAt},
+					RobotID:           1,
+					RobotName:         "robot$library+puller",
+				},
+			}
+			scheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(scheme)).Should(Succeed())
+			c := fake.NewFakeClientWithScheme(scheme,
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "harbor-admin", Namespace: namespace},
+					Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("Harbor12345")},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "puller", Namespace: namespace},
+					Type:       corev1.SecretTypeDockerConfigJson,
+					Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"harbor.example.com":{"auth":"` +
+						base64.StdEncoding.EncodeToString([]byte("robot$library+puller:secret-1")) + `"}}}`)},
+				},
+			)
+			log := ctrl.Log.WithName("harbor")
+			r := &HarborRobotCredentialsReconciler{
+				CredentialsReconciler: CredentialsReconciler{Client: c, Log: log},
+				Client:                c,
+				Log:                   log,
+			}
+
+			credentials, err := r.Authenticate(r.Log, harborRobotCredentials, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(credentials.Rotated).Should(BeFalse())
+			Expect(credentials.ExpiresAt.Equal(expiresAt.Time)).Should(BeTrue())
+			_, password, err := credentials.Auths[0].basicAuth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).Should(Equal("secret-1"))
+			Expect(server.robots[1].Secret).Should(Equal("secret-1"))
+
+			By("By rotating the secret when forced")
+			credentials, err = r.Authenticate(r.Log, harborRobotCredentials, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(credentials.Rotated).Should(BeTrue())
+			_, password, err = credentials.Auths[0].basicAuth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).Should(Equal("secret-1-rotated"))
+		})
+
+		It("Should not adopt robot accounts created by hand", func() {
+			server := newHarborServer()
+			defer server.Close()
+			server.robots[1] = &harborRobot{ID: 1, Name: "robot$library+puller", Level: "project", Secret: "manual"}
+			server.nextID = 2
+			c := &harborClient{url: server.URL, username: "admin", password: "Harbor12345"}
+
+			_, _, err := c.ensureRobot(0, desired("puller", "owner-uid"), false)
+			Expect(err).To(HaveOccurred())
+			Expect(isConflict(err)).Should(BeTrue())
+			Expect(server.robots[1].Secret).Should(Equal("manual"))
+		})
+
+		It("Should match robot names whatever their prefix", func() {
+			Expect(isHarborRobotName("robot$library+puller", "library", "puller")).Should(BeTrue())
+			Expect(isHarborRobotName("bot+library+puller", "library", "puller")).Should(BeTrue())
+			Expect(isHarborRobotName("robot$mylibrary+puller", "library", "puller")).Should(BeFalse())
+			Expect(isHarborRobotName("robot$library+puller2", "library", "puller")).Should(BeFalse())
+		})
+	})
+})
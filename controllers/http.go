@@ -0,0 +1,153 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// httpTimeout bounds the requests to the registry and token APIs
+	httpTimeout = 30 * time.Second
+	// maxErrorBodySize is the maximum number of bytes of an error response kept in the error message
+	maxErrorBodySize = 1024
+)
+
+// httpClient is used for the registry and token APIs reached over plain HTTP
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// oauth2Token is the response of an OAuth2 token endpoint
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// httpError is returned when an HTTP API answers with an unexpected status
+type httpError struct {
+	URL        string
+	StatusCode int
+	// Code is the OAuth2 error code of the response, if any
+	Code    string
+	Message string
+}
+
+func (e *httpError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s returned %d %s: %s", e.URL, e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s returned %d: %s", e.URL, e.StatusCode, e.Message)
+}
+
+// newHTTPError returns the error of an unexpected response, including the OAuth2
+// error code and description when the body holds them
+func newHTTPError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	err := &httpError{
+		URL:        resp.Request.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+	}
+
+	var oauthErr struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+		err.Code = oauthErr.Error
+		if oauthErr.ErrorDescription != "" {
+			err.Message = oauthErr.ErrorDescription
+		}
+	}
+
+	return err
+}
+
+// doJSON sends req and decodes its JSON response into out. Responses
+// other than 2xx are returned as an httpError.
+func doJSON(req *http.Request, out interface{}) error {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode the response of %s: %v", req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// postForm posts the URL encoded form to endpoint and decodes its JSON response into out
+func postForm(endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doJSON(req, out)
+}
+
+// isHTTPUnauthorized returns true if the HTTP API rejected the provided credentials
+func isHTTPUnauthorized(err error) bool {
+	herr, ok := err.(*httpError)
+	if !ok {
+		return false
+	}
+
+	switch herr.Code {
+	case "invalid_grant", "invalid_client", "unauthorized_client":
+		return true
+	}
+	return herr.StatusCode == http.StatusUnauthorized || herr.StatusCode == http.StatusForbidden
+}
+
+// isHTTPTransient returns true if the HTTP error is expected to be solved by retrying,
+// e.g. network errors, throttling or server errors
+func isHTTPTransient(err error) bool {
+	switch e := err.(type) {
+	case *httpError:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+	case *url.Error:
+		return true
+	case net.Error:
+		return true
+	}
+	return false
+}
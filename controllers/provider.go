@@ -0,0 +1,563 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CredentialsObject is a credentials kind reconciled with a RegistryProvider
+type CredentialsObject interface {
+	client.Object
+	GetCredentialsSpec() *registryv1alpha1.CredentialsSpec
+	GetCredentialsStatus() *registryv1alpha1.CredentialsStatus
+}
+
+// RegistryProvider authenticates credentials objects against a registry. The
+// CredentialsReconciler handles their status, secrets, refreshes and events.
+type RegistryProvider interface {
+	// Authenticate returns the registry credentials of object. Providers caching
+	// tokens must request a new one when force is set.
+	Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error)
+
+	// IsUnauthorized returns true if the registry rejected the identity of the object
+	IsUnauthorized(err error) bool
+
+	// IsTransient returns true if the error is expected to be solved by retrying,
+	// e.g. throttling or network errors
+	IsTransient(err error) bool
+}
+
+// StatusReporter is implemented by providers setting kind specific status
+// fields after a successful authentication
+type StatusReporter interface {
+	ReportStatus(object CredentialsObject, credentials *RegistryCredentials)
+}
+
+// CleanupProvider is implemented by providers owning resources in the registry,
+// e.g. robot accounts, which are released before the finalizer is removed
+type CleanupProvider interface {
+	Cleanup(log logr.Logger, object CredentialsObject, deletionPolicy registryv1alpha1.DeletionPolicy) error
+}
+
+// credentialsKind is the wiring of a credentials kind to its controller
+type credentialsKind struct {
+	// name is the key of the reconciled objects in the logs
+	name      string
+	newObject func() CredentialsObject
+	newList   func() client.ObjectList
+
+	// secretRefField indexes the objects by the Secret returned by secretRef, empty
+	// when they don't reference any, so that they are reconciled when it changes
+	secretRefField string
+	secretRef      func(object CredentialsObject) string
+}
+
+// reconcileKind reconciles the object of the request with the provider of its kind
+func (r *CredentialsReconciler) reconcileKind(ctx context.Context, log logr.Logger, req ctrl.Request, kind credentialsKind, provider RegistryProvider) (ctrl.Result, error) {
+	return r.reconcileCredentials(ctx, log.WithValues(kind.name, req.NamespacedName), req, kind.newObject(), provider)
+}
+
+// setupKindWithManager sets up the controller of a credentials kind with the Manager
+func (r *CredentialsReconciler) setupKindWithManager(mgr ctrl.Manager, log logr.Logger, kind credentialsKind, reconciler reconcile.Reconciler) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), kind.newObject(), kind.secretRefField, func(object client.Object) []string {
+		if name := kind.secretRef(object.(CredentialsObject)); name != "" {
+			return []string{name}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(kind.newObject()).
+		Owns(&corev1.Secret{}).
+		WithOptions(controller.Options{RateLimiter: failureRateLimiter()}).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findKindForSecret(log, kind)),
+		).
+		Complete(reconciler)
+}
+
+// findKindForSecret returns a request for every object of the kind referencing the given Secret
+func (r *CredentialsReconciler) findKindForSecret(log logr.Logger, kind credentialsKind) handler.MapFunc {
+	return func(secret client.Object) []reconcile.Request {
+		list := kind.newList()
+		err := r.List(context.Background(), list, &client.ListOptions{
+			Namespace:     secret.GetNamespace(),
+			FieldSelector: fields.OneTermEqualSelector(kind.secretRefField, secret.GetName()),
+		})
+		if err != nil {
+			log.Error(err, "Unable to list credentials", "secret", secret.GetName())
+			return []reconcile.Request{}
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			log.Error(err, "Unable to list credentials", "secret", secret.GetName())
+			return []reconcile.Request{}
+		}
+
+		requests := make([]reconcile.Request, len(items))
+		for i, item := range items {
+			object := item.(client.Object)
+			requests[i] = reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      object.GetName(),
+					Namespace: object.GetNamespace(),
+				},
+			}
+		}
+		return requests
+	}
+}
+
+// reconcileCredentials reconciles the credentials object of the request with the provider
+func (r *CredentialsReconciler) reconcileCredentials(ctx context.Context, log logr.Logger, req ctrl.Request, object CredentialsObject, provider RegistryProvider) (ctrl.Result, error) {
+	// Skip if the object doesn't exists
+	if err := r.Get(ctx, req.NamespacedName, object); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Unable to get credentials")
+		return ctrl.Result{}, err
+	}
+
+	// object is not going to be deleted
+	if object.GetDeletionTimestamp().IsZero() {
+
+		// Register the finalizer to clean up the generated secrets
+		if !controllerutil.ContainsFinalizer(object, credentialsFinalizer) {
+			controllerutil.AddFinalizer(object, credentialsFinalizer)
+			if err := r.Update(ctx, object); err != nil {
+				log.Error(err, "Unable to add finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+
+		// If Authenticating status if is not set
+		status := object.GetCredentialsStatus()
+		if meta.FindStatusCondition(status.Conditions, registryv1alpha1.ConditionReady) == nil {
+			if err := r.setCredentialsStatus(log, object, metav1.Condition{
+				Type:    registryv1alpha1.ConditionReady,
+				Status:  metav1.ConditionUnknown,
+				Reason:  registryv1alpha1.ReasonAuthenticating,
+				Message: "Authenticating against the registry",
+			}); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		// Keep the generated secret as is while suspended
+		if object.GetCredentialsSpec().Suspend {
+			return ctrl.Result{}, r.setCredentialsStatus(log, object, metav1.Condition{
+				Type:    registryv1alpha1.ConditionSuspended,
+				Status:  metav1.ConditionTrue,
+				Reason:  registryv1alpha1.ReasonSuspended,
+				Message: "Token refreshes are suspended",
+			})
+		}
+		if meta.IsStatusConditionTrue(status.Conditions, registryv1alpha1.ConditionSuspended) {
+			if err := r.setCredentialsStatus(log, object, metav1.Condition{
+				Type:    registryv1alpha1.ConditionSuspended,
+				Status:  metav1.ConditionFalse,
+				Reason:  registryv1alpha1.ReasonResumed,
+				Message: "Token refreshes are resumed",
+			}); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return r.refreshCredentials(log, object, provider)
+	} else {
+		// Skip if the generated secrets have already been released
+		if !controllerutil.ContainsFinalizer(object, credentialsFinalizer) {
+			return ctrl.Result{}, nil
+		}
+
+		// Set Terminating status
+		if err := r.setCredentialsStatus(log, object, metav1.Condition{
+			Type:    registryv1alpha1.ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  registryv1alpha1.ReasonTerminating,
+			Message: "Credentials are being deleted",
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		deletionPolicy := object.GetCredentialsSpec().DeletionPolicy
+		if deletionPolicy == "" {
+			deletionPolicy = registryv1alpha1.DeletionPolicyDelete
+		}
+		if cleaner, ok := provider.(CleanupProvider); ok {
+			if err := cleaner.Cleanup(log, object, deletionPolicy); err != nil {
+				log.Error(err, "Unable to clean up registry resources")
+				r.Recorder.Eventf(object, corev1.EventTypeWarning, "CleanupFailed", "Unable to clean up registry resources: %v", err)
+				return ctrl.Result{}, err
+			}
+		}
+		if err := r.cleanupSecrets(log, object, deletionPolicy); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		controllerutil.RemoveFinalizer(object, credentialsFinalizer)
+		if err := r.Update(ctx, object); err != nil {
+			log.Error(err, "Unable to remove finalizer")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+}
+
+// refreshCredentials authenticates with the provider and writes the generated secret,
+// unless the current token is still fresh and the secret is in sync
+func (r *CredentialsReconciler) refreshCredentials(log logr.Logger, object CredentialsObject, provider RegistryProvider) (ctrl.Result, error) {
+	spec := object.GetCredentialsSpec()
+	status := object.GetCredentialsStatus()
+
+	refreshBefore := defaultRefreshBefore
+	if spec.RefreshBefore != nil {
+		refreshBefore = spec.RefreshBefore.Duration
+	}
+
+	// Skip if the token is still fresh, the secret is in sync and no refresh is requested
+	refreshRequest := object.GetAnnotations()[registryv1alpha1.RefreshRequestedAtAnnotation]
+	refreshRequested := refreshRequest != "" && refreshRequest != status.LastHandledRefreshRequest
+	drifted := false
+	if refreshRequested {
+		log.Info("Refresh requested", "refreshRequestedAt", refreshRequest)
+	} else if isTokenFresh(object, statusRefreshBefore(status, refreshBefore)) {
+		var err error
+		drifted, err = r.isSecretDrifted(object.GetNamespace(), status.SecretName, status.SecretHash)
+		if err != nil {
+			log.Error(err, "Unable to get Secret")
+			return ctrl.Result{}, err
+		}
+		if !drifted {
+			current := RegistryCredentials{}
+			if status.ExpiresAt != nil {
+				current.ExpiresAt = &status.ExpiresAt.Time
+			}
+			return requeueBeforeExpiration(current, statusRefreshBefore(status, refreshBefore)), nil
+		}
+		log.Info("Secret drifted, restoring it", "secret", status.SecretName)
+	}
+
+	// Requested refreshes skip the tokens cached by the provider
+	credentials, err := provider.Authenticate(log, object, refreshRequested)
+	if err != nil {
+		if err := r.setCredentialsError(log, object, provider, registryv1alpha1.ConditionAuthenticated, err); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Retry with exponential backoff
+		return retryResult(object, provider, err), nil
+	}
+
+	gvk, err := apiutil.GVKForObject(object, r.Scheme)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	credentials.Name = object.GetName()
+	credentials.Namespace = object.GetNamespace()
+	credentials.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(object, gvk),
+	}
+
+	secret, err := r.getSecret(*credentials, spec.SecretTemplate)
+	if err == nil {
+		err = r.createOrUpdateSecret(log, &secret)
+	}
+	if err == nil {
+		err = r.deleteRenamedSecret(log, object, status.SecretName, &secret)
+	}
+	if err != nil {
+		// The last token is no longer valid once rotated, the credentials are not Ready
+		// until the Secret is written with new ones
+		if credentials.Rotated {
+			now := metav1.Now()
+			status.ExpiresAt = &now
+		}
+		if err := r.setCredentialsError(log, object, provider, registryv1alpha1.ConditionSecretSynced, err); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Retry with exponential backoff
+		return retryResult(object, provider, err), nil
+	}
+
+	if drifted {
+		r.Recorder.Eventf(object, corev1.EventTypeNormal, "DriftCorrected", "Restored secret %q", secret.ObjectMeta.Name)
+	}
+	if refreshRequested {
+		status.LastHandledRefreshRequest = refreshRequest
+		r.Recorder.Eventf(object, corev1.EventTypeNormal, "Refreshed", "Refreshed token as requested at %s", refreshRequest)
+	}
+
+	// Set Authenticated status
+	if reporter, ok := provider.(StatusReporter); ok {
+		reporter.ReportStatus(object, credentials)
+	}
+	if err := r.setCredentialsAuthenticated(log, object, credentials, &secret); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Refresh the token before it expires
+	if credentials.ExpiresAt != nil {
+		refreshBefore = boundRefreshBefore(refreshBefore, time.Now(), *credentials.ExpiresAt)
+	}
+	return requeueBeforeExpiration(*credentials, refreshBefore), nil
+}
+
+// setCredentialsError sets the failed conditionType to False with the error as message. The
+// credentials are only marked as not Ready when the last token has expired, otherwise they are Degraded.
+func (r *CredentialsReconciler) setCredentialsError(log logr.Logger, object CredentialsObject, provider RegistryProvider, conditionType string, err error) error {
+	status := object.GetCredentialsStatus()
+
+	reason := registryv1alpha1.ReasonAuthenticationFailed
+	switch {
+	case isConflict(err):
+		reason = registryv1alpha1.ReasonConflict
+	case conditionType == registryv1alpha1.ConditionSecretSynced:
+		reason = registryv1alpha1.ReasonSecretSyncFailed
+	case provider.IsUnauthorized(err):
+		reason = registryv1alpha1.ReasonUnauthorized
+	}
+
+	conditions := []metav1.Condition{
+		{
+			Type:    conditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		},
+		{
+			Type:    registryv1alpha1.ConditionDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: err.Error(),
+		},
+	}
+
+	if isTokenValid(object) {
+		log.Info("Keeping the last valid token", "expiresAt", status.ExpiresAt)
+	} else {
+		readyReason := reason
+		if status.ExpiresAt != nil {
+			readyReason = registryv1alpha1.ReasonTokenExpired
+		}
+		conditions = append(conditions, metav1.Condition{
+			Type:    registryv1alpha1.ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  readyReason,
+			Message: err.Error(),
+		})
+	}
+
+	status.ErrorMessage = err.Error()
+	return r.setCredentialsStatus(log, object, conditions...)
+}
+
+// setCredentialsAuthenticated sets the Authenticated status with the details of the refreshed token
+func (r *CredentialsReconciler) setCredentialsAuthenticated(log logr.Logger, object CredentialsObject, credentials *RegistryCredentials, secret *corev1.Secret) error {
+	now := metav1.Now()
+	status := object.GetCredentialsStatus()
+	status.ErrorMessage = ""
+	status.ExpiresAt = nil
+	if credentials.ExpiresAt != nil {
+		expiresAt := metav1.NewTime(*credentials.ExpiresAt)
+		status.ExpiresAt = &expiresAt
+	}
+	status.LastRefreshTime = &now
+	status.RegistryHosts = make([]string, len(credentials.Auths))
+	for i, auth := range credentials.Auths {
+		status.RegistryHosts[i] = auth.Host
+	}
+	status.SecretName = secret.ObjectMeta.Name
+	status.SecretHash = getSecretHash(secret)
+
+	authenticatedMessage := "Authenticated against the registry"
+	if credentials.Identity != "" {
+		authenticatedMessage = fmt.Sprintf("Authenticated as %s", credentials.Identity)
+	}
+
+	return r.setCredentialsStatus(log, object,
+		metav1.Condition{
+			Type:    registryv1alpha1.ConditionAuthenticated,
+			Status:  metav1.ConditionTrue,
+			Reason:  registryv1alpha1.ReasonAuthenticated,
+			Message: authenticatedMessage,
+		},
+		metav1.Condition{
+			Type:    registryv1alpha1.ConditionSecretSynced,
+			Status:  metav1.ConditionTrue,
+			Reason:  registryv1alpha1.ReasonSecretSynced,
+			Message: fmt.Sprintf("Secret %q is up to date", status.SecretName),
+		},
+		metav1.Condition{
+			Type:    registryv1alpha1.ConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  registryv1alpha1.ReasonAuthenticated,
+			Message: fmt.Sprintf("Secret %q holds valid credentials", status.SecretName),
+		},
+		metav1.Condition{
+			Type:    registryv1alpha1.ConditionDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  registryv1alpha1.ReasonReconciled,
+			Message: "Last reconciliation succeeded",
+		},
+	)
+}
+
+// setCredentialsStatus sets the given conditions, derives the phase from them and updates the status
+func (r *CredentialsReconciler) setCredentialsStatus(log logr.Logger, object CredentialsObject, conditions ...metav1.Condition) error {
+	ctx := context.Background()
+
+	status := object.GetCredentialsStatus()
+	status.ObservedGeneration = object.GetGeneration()
+	for _, condition := range conditions {
+		condition.ObservedGeneration = object.GetGeneration()
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+	status.Phase = credentialsPhase(object)
+
+	if err := r.Status().Update(ctx, object); err != nil {
+		log.Error(err, "Unable to set status")
+		return err
+	}
+
+	return nil
+}
+
+// credentialsPhase summarizes the status conditions as a phase
+func credentialsPhase(object CredentialsObject) registryv1alpha1.CredentialsPhase {
+	if !object.GetDeletionTimestamp().IsZero() {
+		return registryv1alpha1.CredentialsTerminating
+	}
+
+	conditions := object.GetCredentialsStatus().Conditions
+	if meta.IsStatusConditionTrue(conditions, registryv1alpha1.ConditionSuspended) {
+		return registryv1alpha1.CredentialsSuspended
+	}
+
+	ready := meta.FindStatusCondition(conditions, registryv1alpha1.ConditionReady)
+	switch {
+	case ready == nil || ready.Status == metav1.ConditionUnknown:
+		return registryv1alpha1.CredentialsAuthenticating
+	case ready.Status == metav1.ConditionTrue && meta.IsStatusConditionTrue(conditions, registryv1alpha1.ConditionDegraded):
+		return registryv1alpha1.CredentialsDegraded
+	case ready.Status == metav1.ConditionTrue:
+		return registryv1alpha1.CredentialsAuthenticated
+	}
+
+	authenticated := meta.FindStatusCondition(conditions, registryv1alpha1.ConditionAuthenticated)
+	if authenticated != nil && authenticated.Status == metav1.ConditionFalse && authenticated.Reason == registryv1alpha1.ReasonUnauthorized {
+		return registryv1alpha1.CredentialsUnauthorized
+	}
+	return registryv1alpha1.CredentialsError
+}
+
+// isTokenFresh returns true when the current token was generated for the current generation
+// by a successful refresh and it doesn't have to be refreshed yet
+func isTokenFresh(object CredentialsObject, refreshBefore time.Duration) bool {
+	status := object.GetCredentialsStatus()
+	ready := meta.FindStatusCondition(status.Conditions, registryv1alpha1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionTrue || ready.ObservedGeneration != object.GetGeneration() {
+		return false
+	}
+	if status.SecretName == "" {
+		return false
+	}
+
+	// Degraded credentials keep retrying until a refresh succeeds
+	if meta.IsStatusConditionTrue(status.Conditions, registryv1alpha1.ConditionDegraded) {
+		return false
+	}
+
+	// Credentials without expiration are validated again every staticRefreshInterval
+	if status.ExpiresAt == nil {
+		return status.LastRefreshTime != nil && time.Now().Before(status.LastRefreshTime.Add(staticRefreshInterval))
+	}
+
+	return time.Now().Before(status.ExpiresAt.Add(-refreshBefore))
+}
+
+// boundRefreshBefore returns refreshBefore, or half the lifetime of the token issued at issuedAt
+// when the refresh window covers it entirely, so that short-lived tokens, e.g. 1h OAuth2
+// access tokens with the default refreshBefore, aren't refreshed continuously
+func boundRefreshBefore(refreshBefore time.Duration, issuedAt, expiresAt time.Time) time.Duration {
+	if lifetime := expiresAt.Sub(issuedAt); lifetime > 0 && refreshBefore >= lifetime {
+		return lifetime / 2
+	}
+	return refreshBefore
+}
+
+// statusRefreshBefore returns refreshBefore bounded by the lifetime of the current token
+func statusRefreshBefore(status *registryv1alpha1.CredentialsStatus, refreshBefore time.Duration) time.Duration {
+	if status.LastRefreshTime == nil || status.ExpiresAt == nil {
+		return refreshBefore
+	}
+	return boundRefreshBefore(refreshBefore, status.LastRefreshTime.Time, status.ExpiresAt.Time)
+}
+
+// isTokenValid returns true when the generated secret holds a token that hasn't expired yet,
+// or credentials without expiration
+func isTokenValid(object CredentialsObject) bool {
+	status := object.GetCredentialsStatus()
+	if !meta.IsStatusConditionTrue(status.Conditions, registryv1alpha1.ConditionReady) {
+		return false
+	}
+
+	return status.ExpiresAt == nil || time.Now().Before(status.ExpiresAt.Time)
+}
+
+// retryResult returns the result retrying a failed reconciliation. Transient errors are
+// retried with exponential backoff and the rest after failureMaxDelay, or when the last
+// valid token expires if it happens earlier.
+func retryResult(object CredentialsObject, provider RegistryProvider, err error) ctrl.Result {
+	if isTokenValid(object) && object.GetCredentialsStatus().ExpiresAt != nil {
+		if untilExpiration := time.Until(object.GetCredentialsStatus().ExpiresAt.Time); untilExpiration < failureMaxDelay {
+			return ctrl.Result{RequeueAfter: untilExpiration}
+		}
+	}
+
+	if !provider.IsTransient(err) {
+		return ctrl.Result{RequeueAfter: failureMaxDelay}
+	}
+
+	return ctrl.Result{Requeue: true}
+}
@@ -0,0 +1,601 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Credentials reconciler", func() {
+
+	Context("When checking whether the token has to be refreshed", func() {
+		newECRCredentials := func(expiresAt time.Time, observedGeneration int64) *registryv1alpha1.ECRCredentials {
+			expires := metav1.NewTime(expiresAt)
+			return &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Status: registryv1alpha1.ECRCredentialsStatus{
+					CredentialsStatus: registryv1alpha1.CredentialsStatus{
+						ExpiresAt:  &expires,
+						SecretName: "credentials",
+						Conditions: []metav1.Condition{
+							{Type: registryv1alpha1.ConditionReady, Status: metav1.ConditionTrue, ObservedGeneration: observedGeneration},
+						},
+					},
+				},
+			}
+		}
+		provider := &ECRCredentialsReconciler{}
+		throttled := awserr.New("Throttling", "Rate exceeded", nil)
+
+		It("Should keep tokens expiring after the refresh window", func() {
+			Expect(isTokenFresh(newECRCredentials(time.Now().Add(2*time.Hour), 2), time.Hour)).Should(BeTrue())
+		})
+
+		It("Should refresh tokens within the refresh window", func() {
+			Expect(isTokenFresh(newECRCredentials(time.Now().Add(30*time.Minute), 2), time.Hour)).Should(BeFalse())
+		})
+
+		It("Should refresh tokens of a previous generation", func() {
+			Expect(isTokenFresh(newECRCredentials(time.Now().Add(2*time.Hour), 1), time.Hour)).Should(BeFalse())
+		})
+
+		It("Should keep serving valid tokens when a refresh fails", func() {
+			ecrCredentials := newECRCredentials(time.Now().Add(2*time.Minute), 2)
+			Expect(isTokenValid(ecrCredentials)).Should(BeTrue())
+			Expect(retryResult(ecrCredentials, provider, throttled).RequeueAfter).Should(BeNumerically("<=", 2*time.Minute))
+
+			ecrCredentials.Status.Conditions = append(ecrCredentials.Status.Conditions, metav1.Condition{
+				Type:   registryv1alpha1.ConditionDegraded,
+				Status: metav1.ConditionTrue,
+			})
+			Expect(credentialsPhase(ecrCredentials)).Should(Equal(registryv1alpha1.CredentialsDegraded))
+			Expect(isTokenFresh(ecrCredentials, time.Minute)).Should(BeFalse())
+		})
+
+		It("Should not serve expired tokens", func() {
+			ecrCredentials := newECRCredentials(time.Now().Add(-time.Minute), 2)
+			Expect(isTokenValid(ecrCredentials)).Should(BeFalse())
+			Expect(retryResult(ecrCredentials, provider, throttled).Requeue).Should(BeTrue())
+		})
+
+		It("Should delay the retries of errors that are not transient", func() {
+			ecrCredentials := newECRCredentials(time.Now().Add(-time.Minute), 2)
+			err := awserr.New("UnrecognizedClientException", "The security token included in the request is invalid", nil)
+			Expect(retryResult(ecrCredentials, provider, err).RequeueAfter).Should(Equal(failureMaxDelay))
+		})
+
+		It("Should bound the refresh window of short-lived tokens", func() {
+			issuedAt := time.Now()
+			Expect(boundRefreshBefore(time.Hour, issuedAt, issuedAt.Add(12*time.Hour))).Should(Equal(time.Hour))
+			Expect(boundRefreshBefore(time.Hour, issuedAt, issuedAt.Add(time.Hour))).Should(Equal(30 * time.Minute))
+		})
+
+		It("Should validate credentials without expiration again after an interval", func() {
+			ecrCredentials := newECRCredentials(time.Now(), 2)
+			ecrCredentials.Status.ExpiresAt = nil
+			Expect(isTokenFresh(ecrCredentials, time.Hour)).Should(BeFalse())
+
+			lastRefreshTime := metav1.NewTime(time.Now().Add(-time.Minute))
+			ecrCredentials.Status.LastRefreshTime = &lastRefreshTime
+			Expect(isTokenFresh(ecrCredentials, time.Hour)).Should(BeTrue())
+			Expect(isTokenValid(ecrCredentials)).Should(BeTrue())
+
+			lastRefreshTime = metav1.NewTime(time.Now().Add(-staticRefreshInterval))
+			Expect(isTokenFresh(ecrCredentials, time.Hour)).Should(BeFalse())
+		})
+
+		It("Should detect changes of the secret data", func() {
+			secret := &corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			}
+			hash := getSecretHash(secret)
+			Expect(getSecretHash(secret)).Should(Equal(hash))
+
+			secret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"registry":{}}}`)
+			Expect(getSecretHash(secret)).ShouldNot(Equal(hash))
+		})
+	})
+
+	Context("When scheduling the next refresh", func() {
+		It("Should requeue the refresh window before the expiration", func() {
+			expiresAt := time.Now().Add(12 * time.Hour)
+			result := requeueBeforeExpiration(RegistryCredentials{ExpiresAt: &expiresAt}, time.Hour)
+			Expect(result.RequeueAfter).Should(BeNumerically("~", 11*time.Hour, time.Second))
+		})
+
+		It("Should not requeue more often than the minimum interval", func() {
+			expiresAt := time.Now().Add(30 * time.Minute)
+			Expect(requeueBeforeExpiration(RegistryCredentials{ExpiresAt: &expiresAt}, time.Hour).RequeueAfter).Should(Equal(minRefreshInterval))
+		})
+
+		It("Should validate credentials without expiration again after an interval", func() {
+			Expect(requeueBeforeExpiration(RegistryCredentials{}, time.Hour).RequeueAfter).Should(Equal(staticRefreshInterval))
+		})
+
+		It("Should bound the refresh window with the lifetime of the current token", func() {
+			lastRefreshTime := metav1.NewTime(time.Now())
+			expiresAt := metav1.NewTime(lastRefreshTime.Add(time.Hour))
+			status := &registryv1alpha1.CredentialsStatus{LastRefreshTime: &lastRefreshTime, ExpiresAt: &expiresAt}
+			Expect(statusRefreshBefore(status, 2*time.Hour)).Should(Equal(30 * time.Minute))
+			Expect(statusRefreshBefore(status, 10*time.Minute)).Should(Equal(10 * time.Minute))
+			Expect(statusRefreshBefore(&registryv1alpha1.CredentialsStatus{}, 2*time.Hour)).Should(Equal(2 * time.Hour))
+		})
+
+		It("Should back off failures exponentially up to the maximum delay", func() {
+			rateLimiter := failureRateLimiter()
+			Expect(rateLimiter.When("credentials")).Should(Equal(failureBaseDelay))
+			Expect(rateLimiter.When("credentials")).Should(Equal(2 * failureBaseDelay))
+			for i := 0; i < 10; i++ {
+				rateLimiter.When("credentials")
+			}
+			Expect(rateLimiter.When("credentials")).Should(Equal(failureMaxDelay))
+
+			rateLimiter.Forget("credentials")
+			Expect(rateLimiter.When("credentials")).Should(Equal(failureBaseDelay))
+		})
+	})
+
+	// newStatusReconciler returns a reconciler updating the status of object in a fake client
+	newStatusReconciler := func(object *registryv1alpha1.ECRCredentials) *CredentialsReconciler {
+		scheme := runtime.NewScheme()
+		Expect(registryv1alpha1.AddToScheme(scheme)).Should(Succeed())
+		c := fake.NewFakeClientWithScheme(scheme, object.DeepCopy())
+		Expect(c.Get(context.Background(), client.ObjectKeyFromObject(object), object)).Should(Succeed())
+		return &CredentialsReconciler{Client: c, Log: ctrl.Log.WithName("credentials")}
+	}
+
+	Context("When reporting the refreshed token", func() {
+		It("Should set the token details in the status", func() {
+			ecrCredentials := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default", Generation: 3},
+			}
+			r := newStatusReconciler(ecrCredentials)
+
+			expiresAt := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+			credentials := &RegistryCredentials{
+				Auths: []RegistryAuth{
+					{Host: "111111111111.dkr.ecr.eu-central-1.amazonaws.com"},
+					{Host: "222222222222.dkr.ecr.eu-central-1.amazonaws.com"},
+				},
+				ExpiresAt: &expiresAt,
+				Account:   "111111111111",
+				Identity:  "arn:aws:iam::111111111111:user/puller",
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ecr-pull-secret"},
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			}
+
+			(&ECRCredentialsReconciler{}).ReportStatus(ecrCredentials, credentials)
+			Expect(r.setCredentialsAuthenticated(r.Log, ecrCredentials, credentials, secret)).Should(Succeed())
+
+			fetched := &registryv1alpha1.ECRCredentials{}
+			Expect(r.Get(context.Background(), client.ObjectKeyFromObject(ecrCredentials), fetched)).Should(Succeed())
+			status := fetched.Status
+			Expect(status.ExpiresAt.Time).Should(BeTemporally("==", expiresAt))
+			Expect(status.LastRefreshTime.Time).Should(BeTemporally("~", time.Now(), time.Second))
+			Expect(status.RegistryHosts).Should(Equal([]string{
+				"111111111111.dkr.ecr.eu-central-1.amazonaws.com",
+				"222222222222.dkr.ecr.eu-central-1.amazonaws.com",
+			}))
+			Expect(status.SecretName).Should(Equal("ecr-pull-secret"))
+			Expect(status.SecretHash).Should(Equal(getSecretHash(secret)))
+			Expect(status.AWSAccountID).Should(Equal("111111111111"))
+			Expect(status.CallerArn).Should(Equal("arn:aws:iam::111111111111:user/puller"))
+			Expect(status.ObservedGeneration).Should(Equal(int64(3)))
+			Expect(status.Phase).Should(Equal(registryv1alpha1.CredentialsAuthenticated))
+		})
+	})
+
+	Context("When the conditions transition", func() {
+		It("Should degrade valid tokens and expire them once they are no longer valid", func() {
+			ecrCredentials := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default", Generation: 1},
+			}
+			r := newStatusReconciler(ecrCredentials)
+			provider := &ECRCredentialsReconciler{}
+			condition := func(conditionType string) *metav1.Condition {
+				return meta.FindStatusCondition(ecrCredentials.Status.Conditions, conditionType)
+			}
+
+			By("By authenticating")
+			Expect(r.setCredentialsStatus(r.Log, ecrCredentials, metav1.Condition{
+				Type:   registryv1alpha1.ConditionReady,
+				Status: metav1.ConditionUnknown,
+				Reason: registryv1alpha1.ReasonAuthenticating,
+			})).Should(Succeed())
+			Expect(ecrCredentials.Status.Phase).Should(Equal(registryv1alpha1.CredentialsAuthenticating))
+
+			expiresAt := time.Now().Add(2 * time.Minute)
+			Expect(r.setCredentialsAuthenticated(r.Log, ecrCredentials, &RegistryCredentials{ExpiresAt: &expiresAt},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sample"}})).Should(Succeed())
+			Expect(ecrCredentials.Status.Phase).Should(Equal(registryv1alpha1.CredentialsAuthenticated))
+			Expect(condition(registryv1alpha1.ConditionReady).Status).Should(Equal(metav1.ConditionTrue))
+			Expect(condition(registryv1alpha1.ConditionAuthenticated).Status).Should(Equal(metav1.ConditionTrue))
+			Expect(condition(registryv1alpha1.ConditionSecretSynced).Status).Should(Equal(metav1.ConditionTrue))
+			Expect(condition(registryv1alpha1.ConditionDegraded).Status).Should(Equal(metav1.ConditionFalse))
+
+			By("By keeping the valid token when a refresh fails")
+			throttled := awserr.New("Throttling", "Rate exceeded", nil)
+			Expect(r.setCredentialsError(r.Log, ecrCredentials, provider, registryv1alpha1.ConditionAuthenticated, throttled)).Should(Succeed())
+			Expect(ecrCredentials.Status.Phase).Should(Equal(registryv1alpha1.CredentialsDegraded))
+			Expect(condition(registryv1alpha1.ConditionReady).Status).Should(Equal(metav1.ConditionTrue))
+			Expect(condition(registryv1alpha1.ConditionAuthenticated).Reason).Should(Equal(registryv1alpha1.ReasonAuthenticationFailed))
+			Expect(condition(registryv1alpha1.ConditionDegraded).Status).Should(Equal(metav1.ConditionTrue))
+
+			By("By marking the credentials not ready once the token expired")
+			expired := metav1.NewTime(time.Now().Add(-time.Minute))
+			ecrCredentials.Status.ExpiresAt = &expired
+			unauthorized := awserr.New("UnrecognizedClientException", "The security token included in the request is invalid", nil)
+			Expect(r.setCredentialsError(r.Log, ecrCredentials, provider, registryv1alpha1.ConditionAuthenticated, unauthorized)).Should(Succeed())
+			Expect(ecrCredentials.Status.Phase).Should(Equal(registryv1alpha1.CredentialsUnauthorized))
+			Expect(condition(registryv1alpha1.ConditionReady).Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition(registryv1alpha1.ConditionReady).Reason).Should(Equal(registryv1alpha1.ReasonTokenExpired))
+			Expect(condition(registryv1alpha1.ConditionAuthenticated).Reason).Should(Equal(registryv1alpha1.ReasonUnauthorized))
+
+			By("By failing to sync the secret")
+			Expect(r.setCredentialsError(r.Log, ecrCredentials, provider, registryv1alpha1.ConditionSecretSynced, fmt.Errorf("secret is invalid"))).Should(Succeed())
+			Expect(condition(registryv1alpha1.ConditionSecretSynced).Reason).Should(Equal(registryv1alpha1.ReasonSecretSyncFailed))
+
+			By("By recovering with a new token")
+			Expect(r.setCredentialsAuthenticated(r.Log, ecrCredentials, &RegistryCredentials{ExpiresAt: &expiresAt},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sample"}})).Should(Succeed())
+			Expect(ecrCredentials.Status.Phase).Should(Equal(registryv1alpha1.CredentialsAuthenticated))
+			Expect(ecrCredentials.Status.ErrorMessage).Should(BeEmpty())
+			for _, c := range ecrCredentials.Status.Conditions {
+				Expect(c.ObservedGeneration).Should(Equal(int64(1)))
+			}
+		})
+	})
+
+	Context("When writing the generated secret", func() {
+		desired := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "credentials",
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(&registryv1alpha1.ECRCredentials{ObjectMeta: metav1.ObjectMeta{Name: "sample", UID: "owner-uid"}},
+						registryv1alpha1.GroupVersion.WithKind("ECRCredentials")),
+				},
+			},
+		}
+
+		It("Should update secrets generated for the same owner", func() {
+			Expect(isSecretOwned(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{ownerUIDLabel: "owner-uid"}},
+			}, desired)).Should(BeTrue())
+			Expect(isSecretOwned(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{OwnerReferences: desired.ObjectMeta.OwnerReferences},
+			}, desired)).Should(BeTrue())
+		})
+
+		It("Should not touch secrets of someone else", func() {
+			Expect(isSecretOwned(&corev1.Secret{
+				Type: corev1.SecretTypeTLS,
+			}, desired)).Should(BeFalse())
+			Expect(isSecretOwned(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{ownerUIDLabel: "other-uid"}},
+			}, desired)).Should(BeFalse())
+
+			err := &conflictError{"secret \"credentials\" already exists and is not managed by this object"}
+			Expect(isConflict(err)).Should(BeTrue())
+		})
+
+		It("Should not update secrets whose data is unchanged", func() {
+			existing := desired.DeepCopy()
+			existing.ObjectMeta.ResourceVersion = "42"
+			existing.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)}
+			updated := desired.DeepCopy()
+			updated.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)}
+			Expect(isSecretUpToDate(existing, updated)).Should(BeTrue())
+
+			updated.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"registry":{}}}`)
+			Expect(isSecretUpToDate(existing, updated)).Should(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("Credentials providers", func() {
+
+	const (
+		timeout   = time.Second * 5
+		interval  = time.Second * 1
+		namespace = "default"
+	)
+
+	// providerCase is a credentials object, referencing secret, authenticated against the stand-in
+	// of its provider API and generating a dockerconfigjson with username and password for host
+	type providerCase struct {
+		newServer func() *httptest.Server
+		secret    *corev1.Secret
+		object    func(serverURL string) CredentialsObject
+		host      string
+		username  string
+		password  string
+
+		// check verifies the status fields of the kind, if set
+		check func(object CredentialsObject)
+	}
+
+	gcrCase := func() providerCase {
+		privateKey, key := newServiceAccountKey()
+		return providerCase{
+			newServer: func() *httptest.Server { return newGCRTokenServer(&privateKey.PublicKey) },
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "gcr-service-account"},
+				Data:       map[string][]byte{"key.json": key},
+			},
+			object: func(serverURL string) CredentialsObject {
+				return &registryv1alpha1.GCRCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "gcr-credentials"},
+					Spec: registryv1alpha1.GCRCredentialsSpec{
+						ServiceAccountKeySecretRef: registryv1alpha1.ServiceAccountKeySecretReference{
+							Name: "gcr-service-account",
+						},
+						Registries: []string{"gcr.io", "europe-docker.pkg.dev"},
+						TokenURL:   serverURL,
+					},
+				}
+			},
+			host:     "gcr.io",
+			username: gcrUsername,
+			password: "ya29.token",
+			check: func(object CredentialsObject) {
+				status := object.(*registryv1alpha1.GCRCredentials).Status
+				Expect(status.ClientEmail).Should(Equal("puller@project.iam.gserviceaccount.com"))
+				Expect(status.RegistryHosts).Should(ConsistOf("gcr.io", "europe-docker.pkg.dev"))
+			},
+		}
+	}
+
+	acrCase := func() providerCase {
+		expiresAt := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+		refreshToken := newACRRefreshToken(expiresAt)
+		return providerCase{
+			newServer: func() *httptest.Server { return newACRServer("tenant", "myregistry.azurecr.io", refreshToken) },
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "acr-service-principal"},
+				StringData: map[string]string{"clientId": "client", "clientSecret": "secret"},
+			},
+			object: func(serverURL string) CredentialsObject {
+				return &registryv1alpha1.ACRCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "acr-credentials"},
+					Spec: registryv1alpha1.ACRCredentialsSpec{
+						Registry: "myregistry.azurecr.io",
+						TenantID: "tenant",
+						ServicePrincipalSecretRef: registryv1alpha1.ServicePrincipalSecretReference{
+							Name: "acr-service-principal",
+						},
+						AuthorityURL: serverURL,
+						RegistryURL:  serverURL,
+					},
+				}
+			},
+			host:     "myregistry.azurecr.io",
+			username: acrUsername,
+			password: refreshToken,
+			check: func(object CredentialsObject) {
+				Expect(object.GetCredentialsStatus().ExpiresAt.Time.Equal(expiresAt)).Should(BeTrue())
+			},
+		}
+	}
+
+	ghcrCase := func() providerCase {
+		privateKey, privateKeyPEM := newGitHubAppPrivateKey()
+		return providerCase{
+			newServer: func() *httptest.Server { return newGitHubServer(&privateKey.PublicKey, time.Now().Add(time.Hour)) },
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "github-app"},
+				Data:       map[string][]byte{"private-key.pem": privateKeyPEM},
+			},
+			object: func(serverURL string) CredentialsObject {
+				return &registryv1alpha1.GHCRCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "ghcr-credentials"},
+					Spec: registryv1alpha1.GHCRCredentialsSpec{
+						AppID:          42,
+						InstallationID: 7,
+						PrivateKeySecretRef: registryv1alpha1.PrivateKeySecretReference{
+							Name: "github-app",
+						},
+						APIURL: serverURL,
+					},
+				}
+			},
+			host:     "ghcr.io",
+			username: ghcrUsername,
+			password: "ghs_token",
+		}
+	}
+
+	registryCase := func() providerCase {
+		return providerCase{
+			newServer: func() *httptest.Server { return newRegistryServer("Bearer") },
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "harbor-robot"},
+				Type:       corev1.SecretTypeBasicAuth,
+				StringData: map[string]string{"username": "robot$puller", "password": "secret"},
+			},
+			object: func(serverURL string) CredentialsObject {
+				return &registryv1alpha1.RegistryCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "registry-credentials"},
+					Spec: registryv1alpha1.RegistryCredentialsSpec{
+						Registry: "harbor.example.com",
+						CredentialsSecretRef: &registryv1alpha1.BasicAuthSecretReference{
+							Name: "harbor-robot",
+						},
+						RegistryURL: serverURL,
+					},
+				}
+			},
+			host:     "harbor.example.com",
+			username: "robot$puller",
+			password: "secret",
+			check: func(object CredentialsObject) {
+				Expect(object.GetCredentialsStatus().ExpiresAt).Should(BeNil())
+			},
+		}
+	}
+
+	harborRobotCase := func() providerCase {
+		var server *harborServer
+		return providerCase{
+			newServer: func() *httptest.Server {
+				server = newHarborServer()
+				return server.Server
+			},
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "harbor-admin"},
+				Type:       corev1.SecretTypeBasicAuth,
+				StringData: map[string]string{"username": "admin", "password": "Harbor12345"},
+			},
+			object: func(serverURL string) CredentialsObject {
+				return &registryv1alpha1.HarborRobotCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "harbor-robot-credentials"},
+					Spec: registryv1alpha1.HarborRobotCredentialsSpec{
+						Registry: "harbor.example.com",
+						URL:      serverURL,
+						AdminCredentialsSecretRef: registryv1alpha1.BasicAuthSecretReference{
+							Name: "harbor-admin",
+						},
+						Project:   "library",
+						RobotName: "puller",
+					},
+				}
+			},
+			host:     "harbor.example.com",
+			username: "robot$library+puller",
+			password: "secret-1",
+			check: func(object CredentialsObject) {
+				Expect(object.(*registryv1alpha1.HarborRobotCredentials).Status.RobotID).Should(Equal(int64(1)))
+
+				By("By deleting the robot account with the HarborRobotCredentials")
+				Expect(k8sClient.Delete(context.Background(), object)).Should(Succeed())
+				Eventually(func() int {
+					server.Lock()
+					defer server.Unlock()
+					return len(server.robots)
+				}, timeout, interval).Should(Equal(0))
+			},
+		}
+	}
+
+	DescribeTable("When authenticating with rejected credentials",
+		func(newServer func() *httptest.Server, authenticate func(serverURL string) error) {
+			server := newServer()
+			defer server.Close()
+
+			err := authenticate(server.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(isHTTPUnauthorized(err)).Should(BeTrue())
+			Expect(isHTTPTransient(err)).Should(BeFalse())
+		},
+		Entry("Should report rejected service account keys as unauthorized",
+			func() *httptest.Server {
+				privateKey, _ := newServiceAccountKey()
+				return newGCRTokenServer(&privateKey.PublicKey)
+			},
+			func(serverURL string) error {
+				_, data := newServiceAccountKey()
+				key := &gcrServiceAccountKey{}
+				Expect(json.Unmarshal(data, key)).Should(Succeed())
+				_, err := getGoogleAccessToken(key, serverURL, time.Now())
+				return err
+			},
+		),
+		Entry("Should report rejected service principals as unauthorized",
+			func() *httptest.Server { return newACRServer("tenant", "myregistry.azurecr.io", "") },
+			func(serverURL string) error {
+				_, err := getAADAccessToken(serverURL, "tenant", &acrServicePrincipal{clientID: "client", clientSecret: "invalid"})
+				return err
+			},
+		),
+		Entry("Should report rejected GitHub Apps as unauthorized",
+			func() *httptest.Server {
+				privateKey, _ := newGitHubAppPrivateKey()
+				return newGitHubServer(&privateKey.PublicKey, time.Now().Add(time.Hour))
+			},
+			func(serverURL string) error {
+				_, privateKeyPEM := newGitHubAppPrivateKey()
+				_, err := getGitHubInstallationToken(serverURL, "42", 7, privateKeyPEM, time.Now())
+				return err
+			},
+		),
+		Entry("Should report rejected registry credentials as unauthorized by the Bearer realm",
+			func() *httptest.Server { return newRegistryServer("Bearer") },
+			func(serverURL string) error {
+				return validateRegistryCredentials(serverURL, "", "robot$puller", "invalid")
+			},
+		),
+		Entry("Should report rejected registry credentials as unauthorized with basic auth",
+			func() *httptest.Server { return newRegistryServer("Basic") },
+			func(serverURL string) error {
+				return validateRegistryCredentials(serverURL, "", "robot$puller", "invalid")
+			},
+		),
+		Entry("Should report rejected Harbor admin credentials as unauthorized",
+			func() *httptest.Server { return newHarborServer().Server },
+			func(serverURL string) error {
+				c := &harborClient{url: serverURL, username: "admin", password: "invalid"}
+				_, _, err := c.ensureRobot(0, getHarborRobot(&registryv1alpha1.HarborRobotCredentials{
+					ObjectMeta: metav1.ObjectMeta{Name: "puller", UID: "owner-uid"},
+					Spec:       registryv1alpha1.HarborRobotCredentialsSpec{Project: "library"},
+				}), false)
+				return err
+			},
+		),
+	)
+
+	DescribeTable("When creating credentials",
+		func(c providerCase) {
+			server := c.newServer()
+			defer server.Close()
+			ctx := context.Background()
+
+			By("By creating the referenced Secret")
+			c.secret.ObjectMeta.Namespace = namespace
+			Expect(k8sClient.Create(ctx, c.secret)).Should(Succeed())
+
+			By("By creating the credentials")
+			object := c.object(server.URL)
+			object.SetNamespace(namespace)
+			Expect(k8sClient.Create(ctx, object)).Should(Succeed())
+
+			Eventually(func() registryv1alpha1.CredentialsPhase {
+				k8sClient.Get(ctx, client.ObjectKeyFromObject(object), object)
+				return object.GetCredentialsStatus().Phase
+			}, timeout, interval).Should(Equal(registryv1alpha1.CredentialsAuthenticated))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: object.GetName(), Namespace: namespace}, secret)).Should(Succeed())
+			dockerConfig, err := parseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dockerConfig.Auths[c.host].Username).Should(Equal(c.username))
+			Expect(dockerConfig.Auths[c.host].Password).Should(Equal(c.password))
+
+			if c.check != nil {
+				c.check(object)
+			}
+		},
+		Entry("Should generate a dockerconfigjson for GCRCredentials", gcrCase()),
+		Entry("Should generate a dockerconfigjson for ACRCredentials", acrCase()),
+		Entry("Should generate a dockerconfigjson for GHCRCredentials", ghcrCase()),
+		Entry("Should generate a dockerconfigjson for RegistryCredentials", registryCase()),
+		Entry("Should generate a dockerconfigjson for HarborRobotCredentials", harborRobotCase()),
+	)
+})
@@ -0,0 +1,285 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const credentialsSecretRefField = ".spec.credentialsSecretRef.name"
+
+// RegistryCredentialsReconciler reconciles a RegistryCredentials object
+type RegistryCredentialsReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+// registryCredentialsKind wires RegistryCredentials to their controller, indexed by
+// the Secret holding their username and password
+var registryCredentialsKind = credentialsKind{
+	name:           "registrycredentials",
+	newObject:      func() CredentialsObject { return &registryv1alpha1.RegistryCredentials{} },
+	newList:        func() client.ObjectList { return &registryv1alpha1.RegistryCredentialsList{} },
+	secretRefField: credentialsSecretRefField,
+	secretRef: func(object CredentialsObject) string {
+		registryCredentials := object.(*registryv1alpha1.RegistryCredentials)
+		if registryCredentials.Spec.CredentialsSecretRef == nil {
+			return ""
+		}
+		return registryCredentials.Spec.CredentialsSecretRef.Name
+	},
+}
+
+// registryToken is the response of a Docker Registry v2 token service
+type registryToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentials/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
+func (r *RegistryCredentialsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileKind(ctx, r.Log, req, registryCredentialsKind, r)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RegistryCredentialsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.setupKindWithManager(mgr, r.Log, registryCredentialsKind, r)
+}
+
+// Authenticate implements RegistryProvider with the basic credentials of the Secret or Vault,
+// validated against the registry unless the Basic authType is set
+func (r *RegistryCredentialsReconciler) Authenticate(log logr.Logger, object CredentialsObject, force bool) (*RegistryCredentials, error) {
+	registryCredentials := object.(*registryv1alpha1.RegistryCredentials)
+
+	username, password, err := r.getRegistryBasicAuth(log, registryCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if registryCredentials.Spec.AuthType != registryv1alpha1.RegistryAuthBasic {
+		registryURL := registryCredentials.Spec.RegistryURL
+		if registryURL == "" {
+			registryURL = "https://" + registryCredentials.Spec.Registry
+		}
+		if err := validateRegistryCredentials(registryURL, registryCredentials.Spec.Scope, username, password); err != nil {
+			log.Info("Unable to validate credentials", "registryUrl", registryURL)
+			return nil, err
+		}
+	}
+
+	return &RegistryCredentials{
+		Auths: []RegistryAuth{
+			{
+				Host:               registryCredentials.Spec.Registry,
+				AuthorizationToken: base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+		Identity: username,
+	}, nil
+}
+
+// IsUnauthorized implements RegistryProvider
+func (r *RegistryCredentialsReconciler) IsUnauthorized(err error) bool {
+	return isHTTPUnauthorized(err)
+}
+
+// IsTransient implements RegistryProvider
+func (r *RegistryCredentialsReconciler) IsTransient(err error) bool {
+	return isHTTPTransient(err)
+}
+
+// getRegistryBasicAuth returns the username and password read from Vault or from
+// the Secret referenced by credentialsSecretRef
+func (r *RegistryCredentialsReconciler) getRegistryBasicAuth(log logr.Logger, registryCredentials *registryv1alpha1.RegistryCredentials) (string, string, error) {
+	namespace := registryCredentials.ObjectMeta.Namespace
+	if registryCredentials.Spec.Vault != nil {
+		return r.getVaultBasicAuth(log, namespace, registryCredentials.Spec.Vault)
+	}
+	if registryCredentials.Spec.CredentialsSecretRef == nil {
+		return "", "", fmt.Errorf("credentialsSecretRef or vault must be set")
+	}
+
+	return r.getBasicAuth(log, namespace, *registryCredentials.Spec.CredentialsSecretRef)
+}
+
+// getBasicAuth returns the username and password of the Secret referenced by ref
+func (r *CredentialsReconciler) getBasicAuth(log logr.Logger, namespace string, ref registryv1alpha1.BasicAuthSecretReference) (string, string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), client.ObjectKey{
+		Name:      ref.Name,
+		Namespace: namespace,
+	}, secret); err != nil {
+		log.Info("Unable to get credentials secret", "secret", ref.Name)
+		return "", "", err
+	}
+
+	usernameKey := ref.UsernameKey
+	if usernameKey == "" {
+		usernameKey = corev1.BasicAuthUsernameKey
+	}
+	passwordKey := ref.PasswordKey
+	if passwordKey == "" {
+		passwordKey = corev1.BasicAuthPasswordKey
+	}
+
+	username, ok := secret.Data[usernameKey]
+	if !ok {
+		return "", "", fmt.Errorf("key %q not found in secret %q", usernameKey, ref.Name)
+	}
+	password, ok := secret.Data[passwordKey]
+	if !ok {
+		return "", "", fmt.Errorf("key %q not found in secret %q", passwordKey, ref.Name)
+	}
+
+	return string(username), string(password), nil
+}
+
+// validateRegistryCredentials checks the credentials against the registry answering the
+// Docker Registry v2 authentication challenge of its /v2/ endpoint, either requesting a
+// token to the Bearer realm or retrying the endpoint with basic auth
+func validateRegistryCredentials(registryURL, scope, username, password string) error {
+	pingURL := strings.TrimSuffix(registryURL, "/") + "/v2/"
+	req, err := http.NewRequest(http.MethodGet, pingURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The registry doesn't require authentication
+		return nil
+	case http.StatusUnauthorized:
+	default:
+		return newHTTPError(resp)
+	}
+
+	scheme, params := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || realm.Host == "" {
+			return fmt.Errorf("invalid token realm %q returned by %s", params["realm"], pingURL)
+		}
+		query := realm.Query()
+		if service := params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		if scope != "" {
+			query.Set("scope", scope)
+		}
+		realm.RawQuery = query.Encode()
+
+		req, err = http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+
+		token := &registryToken{}
+		if err := doJSON(req, token); err != nil {
+			return err
+		}
+		if token.Token == "" && token.AccessToken == "" {
+			return fmt.Errorf("no token returned by %s", realm.Redacted())
+		}
+		return nil
+	case "basic":
+		req, err = http.NewRequest(http.MethodGet, pingURL, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+		return doJSON(req, nil)
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q returned by %s", scheme, pingURL)
+	}
+}
+
+// parseAuthChallenge returns the scheme and parameters of a WWW-Authenticate header,
+// e.g. Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseAuthChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+
+	header = strings.TrimSpace(header)
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) < 2 {
+		return header, params
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// Quoted values may hold commas, e.g. scope="repository:foo:pull,push"
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				value, rest = rest, ""
+			} else {
+				value, rest = rest[:end], rest[end+1:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+
+	return parts[0], params
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newRegistryServer returns a stand-in of a registry accepting the robot$puller:secret credentials,
+// answering the /v2/ challenge with the given scheme, and of its token service for the Bearer scheme
+func newRegistryServer(scheme string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, req *http.Request) {
+		if username, password, ok := req.BasicAuth(); scheme == "Basic" && ok && username == "robot$puller" && password == "secret" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if scheme == "Bearer" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/service/token",service="harbor-registry"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/service/token", func(w http.ResponseWriter, req *http.Request) {
+		defer GinkgoRecover()
+		Expect(req.URL.Query().Get("service")).Should(Equal("harbor-registry"))
+		if username, password, ok := req.BasicAuth(); !ok || username != "robot$puller" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+			return
+		}
+		w.Write([]byte(`{"token":"registry-token","expires_in":1800}`))
+	})
+	return server
+}
+
+var _ = Describe("RegistryCredentials controller", func() {
+
+	Context("When validating credentials against a registry", func() {
+		It("Should parse authentication challenges", func() {
+			scheme, params := parseAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+			Expect(scheme).Should(Equal("Bearer"))
+			Expect(params).Should(Equal(map[string]string{
+				"realm":   "https://auth.docker.io/token",
+				"service": "registry.docker.io",
+				"scope":   "repository:library/nginx:pull,push",
+			}))
+		})
+
+		It("Should request a token to the Bearer realm", func() {
+			server := newRegistryServer("Bearer")
+			defer server.Close()
+
+			Expect(validateRegistryCredentials(server.URL, "", "robot$puller", "secret")).Should(Succeed())
+		})
+
+		It("Should retry the registry with basic auth", func() {
+			server := newRegistryServer("Basic")
+			defer server.Close()
+
+			Expect(validateRegistryCredentials(server.URL, "", "robot$puller", "secret")).Should(Succeed())
+		})
+	})
+})
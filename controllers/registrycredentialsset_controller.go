@@ -0,0 +1,428 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// credentialsField indexes RegistryCredentialsSets by their members as Kind/Name
+const credentialsField = ".spec.credentials"
+
+// RegistryCredentialsSetReconciler reconciles a RegistryCredentialsSet object
+type RegistryCredentialsSetReconciler struct {
+	CredentialsReconciler
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentialssets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentialssets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=registry.astrokube.com,resources=registrycredentialssets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile merges the registries of the RegistryCredentialsSet members into a single Secret
+func (r *RegistryCredentialsSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("registrycredentialsset", req.NamespacedName)
+
+	set := &registryv1alpha1.RegistryCredentialsSet{}
+
+	// Skip if the set doesn't exists
+	if err := r.Get(ctx, req.NamespacedName, set); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Unable to get RegistryCredentialsSet")
+		return ctrl.Result{}, err
+	}
+
+	// The generated Secret is garbage collected with the set
+	if !set.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	credentials, excluded, err := r.getMembersCredentials(log, set)
+	if err != nil {
+		if err := r.setStatus(log, set, registryv1alpha1.ReasonSecretSyncFailed, err); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Retry with exponential backoff
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// The ready members are merged without waiting for the others. Members are watched,
+	// the set is reconciled again once they are ready.
+	membersErr := setMembersCondition(set, excluded)
+	if len(excluded) == len(set.Spec.Credentials) {
+		return ctrl.Result{}, r.setStatus(log, set, registryv1alpha1.ReasonMembersNotReady, membersErr)
+	}
+
+	secret, err := r.getSecret(*credentials, nil)
+	if err == nil {
+		err = r.createOrUpdateSecret(log, &secret)
+	}
+	if err == nil {
+		err = r.deleteRenamedSecret(log, set, set.Status.SecretName, &secret)
+	}
+	if err != nil {
+		reason := registryv1alpha1.ReasonSecretSyncFailed
+		if isConflict(err) {
+			reason = registryv1alpha1.ReasonConflict
+		}
+		if err := r.setStatus(log, set, reason, err); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Retry with exponential backoff
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	set.Status.SecretName = secret.ObjectMeta.Name
+	set.Status.RegistryHosts = make([]string, len(credentials.Auths))
+	for i, auth := range credentials.Auths {
+		set.Status.RegistryHosts[i] = auth.Host
+	}
+
+	// The set is not Ready until every member is merged, even though the Secret is written
+	if membersErr != nil {
+		reason := meta.FindStatusCondition(set.Status.Conditions, registryv1alpha1.ConditionMembersReady).Reason
+		return ctrl.Result{}, r.setStatus(log, set, reason, membersErr)
+	}
+
+	return ctrl.Result{}, r.setStatus(log, set, registryv1alpha1.ReasonSecretSynced, nil)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RegistryCredentialsSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Index RegistryCredentialsSets by their members
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &registryv1alpha1.RegistryCredentialsSet{}, credentialsField, func(object client.Object) []string {
+		set := object.(*registryv1alpha1.RegistryCredentialsSet)
+		members := make([]string, len(set.Spec.Credentials))
+		for i, member := range set.Spec.Credentials {
+			members[i] = member.Kind + "/" + member.Name
+		}
+		return members
+	}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&registryv1alpha1.RegistryCredentialsSet{}).
+		Owns(&corev1.Secret{}).
+		WithOptions(controller.Options{RateLimiter: failureRateLimiter()}).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.ECRCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("ECRCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.ECRPublicCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("ECRPublicCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.GCRCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("GCRCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.ACRCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("ACRCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.GHCRCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("GHCRCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.RegistryCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("RegistryCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &registryv1alpha1.HarborRobotCredentials{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForCredentials("HarborRobotCredentials")),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findSetsForSecret),
+		).
+		Complete(r)
+}
+
+// findSetsForCredentials returns a map function requesting every RegistryCredentialsSet
+// with the given credentials object of kind as member
+func (r *RegistryCredentialsSetReconciler) findSetsForCredentials(kind string) handler.MapFunc {
+	return func(object client.Object) []reconcile.Request {
+		return r.findSets(object.GetNamespace(), kind, object.GetName())
+	}
+}
+
+// findSetsForSecret returns a request for every RegistryCredentialsSet whose
+// members own the given Secret, so that the sets follow their refreshes
+func (r *RegistryCredentialsSetReconciler) findSetsForSecret(secret client.Object) []reconcile.Request {
+	owner := metav1.GetControllerOf(secret)
+	if owner == nil || owner.APIVersion != registryv1alpha1.GroupVersion.String() {
+		return []reconcile.Request{}
+	}
+
+	return r.findSets(secret.GetNamespace(), owner.Kind, owner.Name)
+}
+
+func (r *RegistryCredentialsSetReconciler) findSets(namespace, kind, name string) []reconcile.Request {
+	setList := &registryv1alpha1.RegistryCredentialsSetList{}
+	err := r.List(context.Background(), setList, &client.ListOptions{
+		Namespace:     namespace,
+		FieldSelector: fields.OneTermEqualSelector(credentialsField, kind+"/"+name),
+	})
+	if err != nil {
+		r.Log.Error(err, "Unable to list RegistryCredentialsSets", "kind", kind, "name", name)
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(setList.Items))
+	for i, set := range setList.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      set.ObjectMeta.Name,
+				Namespace: set.ObjectMeta.Namespace,
+			},
+		}
+	}
+	return requests
+}
+
+// excludedMember is a member whose registries are left out of the merged Secret
+type excludedMember struct {
+	name    string
+	reason  string
+	message string
+}
+
+// getMembersCredentials merges the registries of the members Secrets. Members that
+// are not authenticated yet or whose Secret can't be merged are returned as excluded.
+func (r *RegistryCredentialsSetReconciler) getMembersCredentials(log logr.Logger, set *registryv1alpha1.RegistryCredentialsSet) (*RegistryCredentials, []excludedMember, error) {
+	ctx := context.Background()
+
+	secretName := set.Spec.SecretName
+	if secretName == "" {
+		secretName = set.ObjectMeta.Name
+	}
+
+	credentials := &RegistryCredentials{
+		Name:      secretName,
+		Namespace: set.ObjectMeta.Namespace,
+		OwnerReferences: []metav1.OwnerReference{
+			*metav1.NewControllerRef(set, registryv1alpha1.GroupVersion.WithKind("RegistryCredentialsSet")),
+		},
+	}
+	excluded := []excludedMember{}
+	hosts := map[string]bool{}
+
+	for _, member := range set.Spec.Credentials {
+		name := member.Kind + "/" + member.Name
+		notReady := excludedMember{name: name, reason: registryv1alpha1.ReasonMembersNotReady, message: "not ready"}
+
+		memberSecretName, ready, err := r.getMemberSecretName(set.ObjectMeta.Namespace, member)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ready {
+			excluded = append(excluded, notReady)
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Name: memberSecretName, Namespace: set.ObjectMeta.Namespace}, secret); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				excluded = append(excluded, notReady)
+				continue
+			}
+			log.Error(err, "Unable to get Secret", "secret", memberSecretName)
+			return nil, nil, err
+		}
+
+		auths, err := getSecretRegistryAuths(secret)
+		if err != nil {
+			reason := registryv1alpha1.ReasonInvalidMembers
+			if _, ok := err.(*unsupportedFormatError); ok {
+				reason = registryv1alpha1.ReasonUnsupportedFormat
+			}
+			excluded = append(excluded, excludedMember{
+				name:    name,
+				reason:  reason,
+				message: fmt.Sprintf("invalid secret %q: %v", memberSecretName, err),
+			})
+			continue
+		}
+
+		// The first member wins on conflicts
+		for _, auth := range auths {
+			if !hosts[auth.Host] {
+				hosts[auth.Host] = true
+				credentials.Auths = append(credentials.Auths, auth)
+			}
+		}
+	}
+
+	return credentials, excluded, nil
+}
+
+// setMembersCondition sets the MembersReady condition of the set, listing the excluded members.
+// It returns an error describing them, nil if every member is merged.
+func setMembersCondition(set *registryv1alpha1.RegistryCredentialsSet, excluded []excludedMember) error {
+	condition := metav1.Condition{
+		Type:               registryv1alpha1.ConditionMembersReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             registryv1alpha1.ReasonMembersReady,
+		Message:            "The registries of every credentials are merged",
+		ObservedGeneration: set.ObjectMeta.Generation,
+	}
+
+	var err error
+	if len(excluded) > 0 {
+		messages := make([]string, len(excluded))
+		condition.Reason = registryv1alpha1.ReasonMembersNotReady
+		for i, member := range excluded {
+			messages[i] = member.name + ": " + member.message
+			// Members that won't be merged until they are fixed take precedence
+			if condition.Reason == registryv1alpha1.ReasonMembersNotReady {
+				condition.Reason = member.reason
+			}
+		}
+		err = fmt.Errorf("credentials not merged: %s", strings.Join(messages, "; "))
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&set.Status.Conditions, condition)
+
+	return err
+}
+
+// getMemberSecretName returns the name of the Secret generated for the member and whether it is ready
+func (r *RegistryCredentialsSetReconciler) getMemberSecretName(namespace string, member registryv1alpha1.CredentialsReference) (string, bool, error) {
+	var object CredentialsObject
+	switch member.Kind {
+	case "ECRCredentials":
+		object = &registryv1alpha1.ECRCredentials{}
+	case "ECRPublicCredentials":
+		object = &registryv1alpha1.ECRPublicCredentials{}
+	case "GCRCredentials":
+		object = &registryv1alpha1.GCRCredentials{}
+	case "ACRCredentials":
+		object = &registryv1alpha1.ACRCredentials{}
+	case "GHCRCredentials":
+		object = &registryv1alpha1.GHCRCredentials{}
+	case "RegistryCredentials":
+		object = &registryv1alpha1.RegistryCredentials{}
+	case "HarborRobotCredentials":
+		object = &registryv1alpha1.HarborRobotCredentials{}
+	default:
+		return "", false, fmt.Errorf("unsupported credentials kind %q", member.Kind)
+	}
+
+	if err := r.Get(context.Background(), client.ObjectKey{Name: member.Name, Namespace: namespace}, object); err != nil {
+		return "", false, client.IgnoreNotFound(err)
+	}
+
+	status := object.GetCredentialsStatus()
+	if !meta.IsStatusConditionTrue(status.Conditions, registryv1alpha1.ConditionReady) {
+		return "", false, nil
+	}
+
+	return status.SecretName, true, nil
+}
+
+// unsupportedFormatError is returned for member Secrets without registries to merge
+type unsupportedFormatError struct {
+	secretType corev1.SecretType
+}
+
+func (e *unsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported format of %s Secret, members must use the dockerconfigjson or dockercfg format", e.secretType)
+}
+
+// getSecretRegistryAuths returns the registries of a Secret with the dockerconfigjson or the legacy dockercfg format
+func getSecretRegistryAuths(secret *corev1.Secret) ([]RegistryAuth, error) {
+	var dockerConfig *DockerConfig
+	var err error
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		dockerConfig, err = parseDockerConfig(data)
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		dockerConfig, err = parseDockerCfg(data)
+	} else {
+		return nil, &unsupportedFormatError{secretType: secret.Type}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return dockerConfig.registryAuths(), nil
+}
+
+// setStatus sets the Ready condition with the given reason, failing with err if not nil
+func (r *RegistryCredentialsSetReconciler) setStatus(log logr.Logger, set *registryv1alpha1.RegistryCredentialsSet, reason string, err error) error {
+	ctx := context.Background()
+
+	condition := metav1.Condition{
+		Type:               registryv1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            fmt.Sprintf("Secret %q is up to date", set.Status.SecretName),
+		ObservedGeneration: set.ObjectMeta.Generation,
+	}
+
+	status := &set.Status
+	status.ErrorMessage = ""
+	status.Phase = registryv1alpha1.RegistryCredentialsSetSynced
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+		status.ErrorMessage = err.Error()
+		status.Phase = registryv1alpha1.RegistryCredentialsSetError
+		if reason == registryv1alpha1.ReasonMembersNotReady {
+			status.Phase = registryv1alpha1.RegistryCredentialsSetPending
+		}
+	}
+	status.ObservedGeneration = set.ObjectMeta.Generation
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if err := r.Status().Update(ctx, set); err != nil {
+		log.Error(err, "Unable to set status")
+		return err
+	}
+
+	return nil
+}
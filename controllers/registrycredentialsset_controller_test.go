@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("RegistryCredentialsSet controller", func() {
+
+	const (
+		timeout   = time.Second * 2
+		interval  = time.Second * 1
+		namespace = "default"
+	)
+
+	Context("When creating RegistryCredentialsSet", func() {
+		It("Should set RegistryCredentialsSet.Status to Pending when members are not ready", func() {
+			By("By creating a new RegistryCredentialsSet")
+			ctx := context.Background()
+			name := "pending-set"
+			r := &registryv1alpha1.RegistryCredentialsSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: registryv1alpha1.RegistryCredentialsSetSpec{
+					Credentials: []registryv1alpha1.CredentialsReference{
+						{Kind: "ECRCredentials", Name: "missing-credentials"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, r)).Should(Succeed())
+
+			fetched := &registryv1alpha1.RegistryCredentialsSet{}
+			Eventually(func() registryv1alpha1.RegistryCredentialsSetPhase {
+				k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      name,
+					Namespace: namespace,
+				}, fetched)
+				return fetched.Status.Phase
+			}, timeout, interval).Should(Equal(registryv1alpha1.RegistryCredentialsSetPending))
+		})
+	})
+
+	Context("When merging members", func() {
+		It("Should report the members left out of the Secret", func() {
+			set := &registryv1alpha1.RegistryCredentialsSet{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+			err := setMembersCondition(set, []excludedMember{
+				{name: "ECRCredentials/pending", reason: registryv1alpha1.ReasonMembersNotReady, message: "not ready"},
+				{name: "GCRCredentials/invalid", reason: registryv1alpha1.ReasonInvalidMembers, message: "invalid secret"},
+			})
+			Expect(err).Should(MatchError("credentials not merged: ECRCredentials/pending: not ready; GCRCredentials/invalid: invalid secret"))
+
+			condition := meta.FindStatusCondition(set.Status.Conditions, registryv1alpha1.ConditionMembersReady)
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(registryv1alpha1.ReasonInvalidMembers))
+			Expect(condition.Message).Should(Equal(err.Error()))
+
+			By("By setting the condition once every member is merged")
+			Expect(setMembersCondition(set, nil)).Should(Succeed())
+			condition = meta.FindStatusCondition(set.Status.Conditions, registryv1alpha1.ConditionMembersReady)
+			Expect(condition.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(condition.ObservedGeneration).Should(Equal(int64(2)))
+		})
+	})
+
+	Context("When reconciling a set with excluded members", func() {
+		It("Should write the Secret and report the set as not Ready", func() {
+			ctx := context.Background()
+			set := &registryv1alpha1.RegistryCredentialsSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "partial-set", Namespace: namespace, Generation: 1},
+				Spec: registryv1alpha1.RegistryCredentialsSetSpec{
+					Credentials: []registryv1alpha1.CredentialsReference{
+						{Kind: "ECRCredentials", Name: "ready-credentials"},
+						{Kind: "ECRCredentials", Name: "missing-credentials"},
+					},
+				},
+			}
+			member := &registryv1alpha1.ECRCredentials{
+				ObjectMeta: metav1.ObjectMeta{Name: "ready-credentials", Namespace: namespace},
+				Status: registryv1alpha1.ECRCredentialsStatus{CredentialsStatus: registryv1alpha1.CredentialsStatus{
+					SecretName: "ready-credentials",
+					Conditions: []metav1.Condition{{Type: registryv1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: registryv1alpha1.ReasonSecretSynced}},
+				}},
+			}
+			memberSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ready-credentials", Namespace: namespace},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"ghcr.io":{"auth":"b2N0b2NhdDp0b2tlbg=="}}}`)},
+			}
+			scheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(scheme)).Should(Succeed())
+			Expect(registryv1alpha1.AddToScheme(scheme)).Should(Succeed())
+			c := fake.NewFakeClientWithScheme(scheme, set, member, memberSecret)
+			log := ctrl.Log.WithName("set")
+			recorder := record.NewFakeRecorder(10)
+			r := &RegistryCredentialsSetReconciler{
+				CredentialsReconciler: CredentialsReconciler{Client: c, Log: log, Recorder: recorder, Scheme: scheme},
+				Client:                c,
+				Log:                   log,
+				Recorder:              recorder,
+				Scheme:                scheme,
+			}
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "partial-set", Namespace: namespace}})
+			Expect(err).ToNot(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "partial-set", Namespace: namespace}, secret)).Should(Succeed())
+			Expect(getSecretRegistryAuths(secret)).Should(HaveLen(1))
+
+			fetched := &registryv1alpha1.RegistryCredentialsSet{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "partial-set", Namespace: namespace}, fetched)).Should(Succeed())
+			Expect(fetched.Status.SecretName).Should(Equal("partial-set"))
+			Expect(fetched.Status.Phase).Should(Equal(registryv1alpha1.RegistryCredentialsSetPending))
+			condition := meta.FindStatusCondition(fetched.Status.Conditions, registryv1alpha1.ConditionReady)
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(registryv1alpha1.ReasonMembersNotReady))
+			Expect(condition.Message).Should(ContainSubstring("ECRCredentials/missing-credentials"))
+		})
+	})
+
+	Context("When reading member secrets", func() {
+		It("Should return the registries of the dockerconfigjson", func() {
+			auths, err := getSecretRegistryAuths(&corev1.Secret{
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(`{"auths":{"public.ecr.aws":{"auth":"cHVibGlj"},"921780870478.dkr.ecr.eu-central-1.amazonaws.com":{"auth":"cHJpdmF0ZQ=="}}}`),
+				},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(auths).Should(Equal([]RegistryAuth{
+				{Host: "921780870478.dkr.ecr.eu-central-1.amazonaws.com", AuthorizationToken: "cHJpdmF0ZQ=="},
+				{Host: "public.ecr.aws", AuthorizationToken: "cHVibGlj"},
+			}))
+		})
+
+		It("Should return the registries of the legacy dockercfg", func() {
+			auths, err := getSecretRegistryAuths(&corev1.Secret{
+				Type: corev1.SecretTypeDockercfg,
+				Data: map[string][]byte{
+					corev1.DockerConfigKey: []byte(`{"ghcr.io":{"username":"octocat","password":"token"}}`),
+				},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(auths).Should(Equal([]RegistryAuth{
+				{Host: "ghcr.io", AuthorizationToken: "b2N0b2NhdDp0b2tlbg=="},
+			}))
+		})
+
+		It("Should reject the formats without registries", func() {
+			_, err := getSecretRegistryAuths(&corev1.Secret{
+				Type: corev1.SecretTypeBasicAuth,
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("octocat"),
+					corev1.BasicAuthPasswordKey: []byte("token"),
+				},
+			})
+			Expect(err).Should(BeAssignableToTypeOf(&unsupportedFormatError{}))
+		})
+	})
+})
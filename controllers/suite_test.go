@@ -22,6 +22,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -81,10 +82,11 @@ var _ = BeforeSuite(func() {
 	Expect(err).ToNot(HaveOccurred())
 
 	credentialsReconciler := CredentialsReconciler{
-		Client:   k8sManager.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("ECRCredentials"),
-		Recorder: k8sManager.GetEventRecorderFor("credentials-controller"),
-		Scheme:   k8sManager.GetScheme(),
+		Client:    k8sManager.GetClient(),
+		Clientset: kubernetes.NewForConfigOrDie(k8sManager.GetConfig()),
+		Log:       ctrl.Log.WithName("controllers").WithName("ECRCredentials"),
+		Recorder:  k8sManager.GetEventRecorderFor("credentials-controller"),
+		Scheme:    k8sManager.GetScheme(),
 	}
 
 	err = (&ECRCredentialsReconciler{
@@ -96,6 +98,69 @@ var _ = BeforeSuite(func() {
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	err = (&ECRPublicCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("ECRPublicCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("ecr-public-credentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&GCRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("GCRCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("gcr-credentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ACRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("ACRCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("acr-credentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&GHCRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("GHCRCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("ghcr-credentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&RegistryCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("RegistryCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("registry-credentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&HarborRobotCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("HarborRobotCredentials"),
+		Recorder:              k8sManager.GetEventRecorderFor("harborrobotcredentials-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&RegistryCredentialsSetReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                k8sManager.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("RegistryCredentialsSet"),
+		Recorder:              k8sManager.GetEventRecorderFor("registry-credentials-set-controller"),
+		Scheme:                k8sManager.GetScheme(),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
 	go func() {
 		err = k8sManager.Start(ctrl.SetupSignalHandler())
 		Expect(err).ToNot(HaveOccurred())
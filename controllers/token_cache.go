@@ -0,0 +1,98 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenCache shares tokens between the credentials objects using the same identity.
+// Concurrent fetches of a key are deduplicated and tokens are evicted once expired.
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]tokenCacheEntry
+	calls   map[string]*tokenCacheCall
+}
+
+type tokenCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// tokenCacheCall is an in-flight fetch awaited by the concurrent gets of its key
+type tokenCacheCall struct {
+	done  chan struct{}
+	entry tokenCacheEntry
+	err   error
+}
+
+// tokenFetcher returns a token and its expiration
+type tokenFetcher func() (interface{}, time.Time, error)
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		entries: map[string]tokenCacheEntry{},
+		calls:   map[string]*tokenCacheCall{},
+	}
+}
+
+// get returns the token cached for key when it is valid for longer than minTTL,
+// fetching a new one otherwise. force skips the cached token.
+func (c *tokenCache) get(key string, minTTL time.Duration, force bool, fetch tokenFetcher) (interface{}, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && !force && time.Until(entry.expiresAt) > minTTL {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+
+	// Wait for the token being fetched for the same key
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.entry.value, call.err
+	}
+
+	call := &tokenCacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	value, expiresAt, err := fetch()
+	call.entry = tokenCacheEntry{value: value, expiresAt: expiresAt}
+	call.err = err
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.evictExpired()
+	if err == nil && time.Now().Before(expiresAt) {
+		c.entries[key] = call.entry
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return value, err
+}
+
+// evictExpired removes the expired tokens, it must be called with the lock held
+func (c *tokenCache) evictExpired() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
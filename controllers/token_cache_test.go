@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token cache", func() {
+
+	Context("When getting tokens", func() {
+		It("Should fetch a token once for concurrent gets", func() {
+			cache := newTokenCache()
+			fetches := 0
+			release := make(chan struct{})
+			fetch := func() (interface{}, time.Time, error) {
+				fetches++
+				<-release
+				return "token", time.Now().Add(12 * time.Hour), nil
+			}
+
+			var wg sync.WaitGroup
+			tokens := make([]interface{}, 10)
+			for i := range tokens {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					tokens[i], _ = cache.get("identity", time.Hour, false, fetch)
+				}(i)
+			}
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			Expect(fetches).Should(Equal(1))
+			for _, token := range tokens {
+				Expect(token).Should(Equal("token"))
+			}
+
+			token, err := cache.get("identity", time.Hour, false, fetch)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(token).Should(Equal("token"))
+			Expect(fetches).Should(Equal(1))
+		})
+
+		It("Should fetch a new token when it is about to expire or forced", func() {
+			cache := newTokenCache()
+			fetches := 0
+			fetch := func() (interface{}, time.Time, error) {
+				fetches++
+				return fetches, time.Now().Add(30 * time.Minute), nil
+			}
+
+			Expect(cache.get("identity", time.Minute, false, fetch)).Should(Equal(1))
+			Expect(cache.get("identity", time.Minute, false, fetch)).Should(Equal(1))
+			Expect(cache.get("identity", time.Hour, false, fetch)).Should(Equal(2))
+			Expect(cache.get("identity", time.Minute, true, fetch)).Should(Equal(3))
+			Expect(cache.get("other-identity", time.Minute, false, fetch)).Should(Equal(4))
+		})
+	})
+})
@@ -1,16 +1,47 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type RegistryCredentials struct {
-	Name               string
-	Namespace          string
+	Name            string
+	Namespace       string
+	Auths           []RegistryAuth
+	ExpiresAt       *time.Time
+	OwnerReferences []metav1.OwnerReference
+
+	// Account and Identity are the authenticated account and identity, when reported by the provider
+	Account  string
+	Identity string
+
+	// Rotated is set when the authentication invalidated the previous credentials, e.g. the
+	// rotated secret of a robot account, so the generated Secret no longer holds valid ones
+	Rotated bool
+}
+
+type RegistryAuth struct {
 	Host               string
 	AuthorizationToken string
-	ExpiresAt          *time.Time
-	OwnerReferences    []metav1.OwnerReference
+}
+
+// basicAuth returns the username and password encoded in the authorization token,
+// e.g. AWS and the password of an ECR token
+func (a RegistryAuth) basicAuth() (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(a.AuthorizationToken)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to decode the authorization token of %s: %v", a.Host, err)
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid authorization token of %s", a.Host)
+	}
+
+	return parts[0], parts[1], nil
 }
@@ -0,0 +1,527 @@
+/*
+Copyright 2021 AstroKube.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/go-logr/logr"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+)
+
+const (
+	// vaultLoginTokenExpiration is the lifetime in seconds of the ServiceAccount tokens used to log in to Vault
+	vaultLoginTokenExpiration = 600
+	// defaultVaultAudience is the audience of the ServiceAccount tokens used to log in to Vault
+	defaultVaultAudience = "vault"
+	// vaultLeaseExpiryWindow is how long before their expiration the AWS credentials of Vault are considered expired
+	vaultLeaseExpiryWindow = time.Minute
+)
+
+// vaultClients caches the Vault clients by login, so that a token is reused while it is
+// valid instead of logging in on every refresh
+var vaultClients = newVaultClientCache()
+
+// vaultSecret is the response of the Vault API
+type vaultSecret struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// vaultClient reads secrets from Vault with the token of a Kubernetes auth login
+type vaultClient struct {
+	address   string
+	namespace string
+	token     string
+	// tokenExpiresAt is the expiration of the token and tokenRenewAt half its lifetime,
+	// zero when it doesn't expire. They are guarded by tokenMu as the token is renewed
+	// while the client is in use.
+	tokenMu        sync.Mutex
+	tokenExpiresAt time.Time
+	tokenRenewAt   time.Time
+	renewable      bool
+
+	// leases are the AWS credentials requested with the token by engine role. Vault
+	// revokes them with the token.
+	mu     sync.Mutex
+	leases map[string]*vaultLease
+}
+
+// vaultLease is a lease of dynamic AWS credentials
+type vaultLease struct {
+	id    string
+	value credentials.Value
+	// expiresAt is the expiration of the lease and renewAt half its lifetime, zero when it doesn't expire
+	expiresAt time.Time
+	renewAt   time.Time
+	renewable bool
+}
+
+// vaultClientCache holds the Vault clients by login key. Concurrent logins of a key are
+// deduplicated and done without holding the lock, so that an unreachable Vault only
+// delays the credentials using it.
+type vaultClientCache struct {
+	mu      sync.Mutex
+	clients map[string]*vaultClient
+	calls   map[string]*vaultClientCall
+}
+
+// vaultClientCall is an in-flight login awaited by the concurrent gets of its key
+type vaultClientCall struct {
+	done   chan struct{}
+	client *vaultClient
+	err    error
+}
+
+func newVaultClientCache() *vaultClientCache {
+	return &vaultClientCache{
+		clients: map[string]*vaultClient{},
+		calls:   map[string]*vaultClientCall{},
+	}
+}
+
+// get returns the client cached for key until half the lifetime of its token, renewing the
+// token when possible. Otherwise a new client is logged in and the previous token expires on its own.
+func (c *vaultClientCache) get(log logr.Logger, key string, login func() (*vaultClient, error)) (*vaultClient, error) {
+	c.mu.Lock()
+	client, ok := c.clients[key]
+	if ok && client.isTokenFresh() {
+		c.mu.Unlock()
+		return client, nil
+	}
+
+	// Wait for the login in progress for the same key
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.client, call.err
+	}
+
+	call := &vaultClientCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.client, call.err = refreshVaultClient(log, client, login)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err == nil {
+		c.clients[key] = call.client
+	} else {
+		delete(c.clients, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.client, call.err
+}
+
+// refreshVaultClient renews the token of client when possible, logging in again otherwise
+func refreshVaultClient(log logr.Logger, client *vaultClient, login func() (*vaultClient, error)) (*vaultClient, error) {
+	if client != nil {
+		if client.isTokenRenewable() {
+			if err := client.renewToken(); err == nil && client.isTokenFresh() {
+				return client, nil
+			}
+		}
+		// The previous token isn't revoked, the credentials of other objects requested with its
+		// leases are still in use. Vault revokes it with its leases once it expires.
+		log.Info("Logging in to Vault again", "address", client.address)
+	}
+
+	return login()
+}
+
+// forget removes client from the cache, e.g. when its token was revoked
+func (c *vaultClientCache) forget(key string, client *vaultClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.clients[key] == client {
+		delete(c.clients, key)
+	}
+}
+
+// getVaultClient returns the cached client of the source, logging in when needed
+func (r *CredentialsReconciler) getVaultClient(log logr.Logger, namespace string, source *registryv1alpha1.VaultSource) (*vaultClient, string, error) {
+	key := strings.Join([]string{namespace, source.Address, source.Namespace,
+		source.Auth.MountPath, source.Auth.Role, source.Auth.ServiceAccountName, source.Auth.Audience}, "\x00")
+
+	c, err := vaultClients.get(log, key, func() (*vaultClient, error) {
+		return r.newVaultClient(log, namespace, source)
+	})
+	return c, key, err
+}
+
+// newVaultClient logs in to Vault with a token of the ServiceAccount of the source,
+// requested through the TokenRequest API for the audience of the source
+func (r *CredentialsReconciler) newVaultClient(log logr.Logger, namespace string, source *registryv1alpha1.VaultSource) (*vaultClient, error) {
+	// The token is sent to the Vault address, it must not be valid for the API server
+	audience := source.Auth.Audience
+	if audience == "" {
+		audience = defaultVaultAudience
+	}
+	jwt, err := r.getServiceAccountToken(context.Background(), namespace, source.Auth.ServiceAccountName, []string{audience}, vaultLoginTokenExpiration)
+	if err != nil {
+		log.Info("Unable to get ServiceAccount token", "serviceAccount", source.Auth.ServiceAccountName)
+		return nil, err
+	}
+
+	c := &vaultClient{
+		address:   strings.TrimSuffix(source.Address, "/"),
+		namespace: source.Namespace,
+	}
+
+	if err := c.login(source.Auth.MountPath, source.Auth.Role, jwt); err != nil {
+		log.Info("Unable to log in to Vault", "address", source.Address, "role", source.Auth.Role)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// login authenticates with the Kubernetes auth method mounted at mountPath
+func (c *vaultClient) login(mountPath, role, jwt string) error {
+	if mountPath == "" {
+		mountPath = "kubernetes"
+	}
+
+	secret := &vaultSecret{}
+	if err := c.do(http.MethodPost, "auth/"+strings.Trim(mountPath, "/")+"/login", map[string]string{
+		"role": role,
+		"jwt":  jwt,
+	}, secret); err != nil {
+		return err
+	}
+	if secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("no client token returned by the Vault login")
+	}
+	c.token = secret.Auth.ClientToken
+	c.setTokenExpiration(secret.Auth.LeaseDuration, secret.Auth.Renewable)
+
+	return nil
+}
+
+// renewToken extends the lifetime of the token
+func (c *vaultClient) renewToken() error {
+	secret := &vaultSecret{}
+	if err := c.do(http.MethodPost, "auth/token/renew-self", map[string]string{}, secret); err != nil {
+		return err
+	}
+	if secret.Auth == nil {
+		return fmt.Errorf("no token returned by the Vault renewal")
+	}
+	c.setTokenExpiration(secret.Auth.LeaseDuration, secret.Auth.Renewable)
+
+	return nil
+}
+
+// setTokenExpiration sets the expiration of the token from its TTL in seconds
+func (c *vaultClient) setTokenExpiration(ttl int64, renewable bool) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	c.tokenExpiresAt, c.tokenRenewAt = vaultExpiration(ttl)
+	c.renewable = renewable
+}
+
+// isTokenFresh returns true until half the lifetime of the token
+func (c *vaultClient) isTokenFresh() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return isVaultTTLFresh(c.tokenRenewAt)
+}
+
+// isTokenRenewable returns true if the lifetime of the token can be extended
+func (c *vaultClient) isTokenRenewable() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	return c.renewable
+}
+
+// do sends a request to the Vault API path and decodes its response into out
+func (c *vaultClient) do(method, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.address+"/v1/"+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	return doJSON(req, out)
+}
+
+// readKV returns the string values of a KV version 2 secret
+func (c *vaultClient) readKV(kv *registryv1alpha1.VaultKVSecret) (map[string]string, error) {
+	mountPath := kv.MountPath
+	if mountPath == "" {
+		mountPath = "secret"
+	}
+
+	secret := &vaultSecret{}
+	if err := c.do(http.MethodGet, strings.Trim(mountPath, "/")+"/data/"+strings.Trim(kv.Path, "/"), nil, secret); err != nil {
+		return nil, err
+	}
+
+	// KV version 2 nests the secret data under data.data
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no data found in Vault secret %q", kv.Path)
+	}
+
+	values := map[string]string{}
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return values, nil
+}
+
+// readAWS returns dynamic credentials of the AWS secrets engine with their expiration, zero
+// when they don't expire. The lease of the previous credentials is reused until half its lifetime,
+// then it is renewed when possible, or new credentials are requested.
+func (c *vaultClient) readAWS(aws *registryv1alpha1.VaultAWSSecret) (credentials.Value, time.Time, error) {
+	mountPath := aws.MountPath
+	if mountPath == "" {
+		mountPath = "aws"
+	}
+	key := strings.Trim(mountPath, "/") + "/creds/" + aws.Role
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leases == nil {
+		c.leases = map[string]*vaultLease{}
+	}
+
+	if lease, ok := c.leases[key]; ok {
+		if !isVaultTTLFresh(lease.renewAt) && lease.renewable && lease.id != "" {
+			// Failed renewals replace the lease
+			c.renewLease(lease)
+		}
+		if isVaultTTLFresh(lease.renewAt) {
+			return lease.value, c.expiration(lease.expiresAt), nil
+		}
+		// Replaced leases are not revoked, the tokens requested with their credentials are
+		// valid until they expire
+		delete(c.leases, key)
+	}
+
+	secret := &vaultSecret{}
+	if err := c.do(http.MethodGet, key, nil, secret); err != nil {
+		return credentials.Value{}, time.Time{}, err
+	}
+
+	value := credentials.Value{ProviderName: "VaultProvider"}
+	value.AccessKeyID, _ = secret.Data["access_key"].(string)
+	value.SecretAccessKey, _ = secret.Data["secret_key"].(string)
+	value.SessionToken, _ = secret.Data["security_token"].(string)
+	if value.AccessKeyID == "" || value.SecretAccessKey == "" {
+		return credentials.Value{}, time.Time{}, fmt.Errorf("no AWS credentials returned by Vault role %q", aws.Role)
+	}
+
+	lease := &vaultLease{
+		id:        secret.LeaseID,
+		value:     value,
+		renewable: secret.Renewable,
+	}
+	lease.expiresAt, lease.renewAt = vaultExpiration(secret.LeaseDuration)
+	c.leases[key] = lease
+
+	return value, c.expiration(lease.expiresAt), nil
+}
+
+// renewLease extends the lease of dynamic credentials
+func (c *vaultClient) renewLease(lease *vaultLease) error {
+	secret := &vaultSecret{}
+	if err := c.do(http.MethodPut, "sys/leases/renew", map[string]string{"lease_id": lease.id}, secret); err != nil {
+		return err
+	}
+	lease.expiresAt, lease.renewAt = vaultExpiration(secret.LeaseDuration)
+	lease.renewable = secret.Renewable
+
+	return nil
+}
+
+// expiration returns the earliest of the lease expiration and the token expiration,
+// as the leases are revoked with the token
+func (c *vaultClient) expiration(leaseExpiresAt time.Time) time.Time {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if leaseExpiresAt.IsZero() || (!c.tokenExpiresAt.IsZero() && c.tokenExpiresAt.Before(leaseExpiresAt)) {
+		return c.tokenExpiresAt
+	}
+	return leaseExpiresAt
+}
+
+// vaultExpiration returns the expiration of a Vault TTL in seconds and half its lifetime,
+// when it is renewed or replaced. A zero TTL never expires.
+func vaultExpiration(ttl int64) (time.Time, time.Time) {
+	if ttl <= 0 {
+		return time.Time{}, time.Time{}
+	}
+	now := time.Now()
+	lifetime := time.Duration(ttl) * time.Second
+	return now.Add(lifetime), now.Add(lifetime / 2)
+}
+
+// isVaultTTLFresh returns true if renewAt is zero or still ahead
+func isVaultTTLFresh(renewAt time.Time) bool {
+	return renewAt.IsZero() || time.Now().Before(renewAt)
+}
+
+// vaultCredentialsProvider provides the AWS credentials read from Vault, expiring with their lease
+type vaultCredentialsProvider struct {
+	credentials.Expiry
+	value credentials.Value
+}
+
+// Retrieve implements credentials.Provider
+func (p *vaultCredentialsProvider) Retrieve() (credentials.Value, error) {
+	return p.value, nil
+}
+
+// getVaultAWSCredentials returns the AWS credentials of the Vault source with their expiration,
+// nil when they don't expire. Dynamic credentials of the AWS secrets engine expire with their
+// lease or the Vault token, KV credentials never expire.
+func (r *CredentialsReconciler) getVaultAWSCredentials(log logr.Logger, namespace string, source *registryv1alpha1.VaultSource) (*credentials.Credentials, *time.Time, error) {
+	c, key, err := r.getVaultClient(log, namespace, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if source.AWS != nil {
+		value, expiresAt, err := c.readAWS(source.AWS)
+		if err != nil {
+			log.Info("Unable to read AWS credentials from Vault", "role", source.AWS.Role)
+			forgetRevokedVaultClient(key, c, err)
+			return nil, nil, err
+		}
+		provider := &vaultCredentialsProvider{value: value}
+		if expiresAt.IsZero() {
+			return credentials.NewCredentials(provider), nil, nil
+		}
+		provider.SetExpiration(expiresAt, vaultLeaseExpiryWindow)
+		return credentials.NewCredentials(provider), &expiresAt, nil
+	}
+
+	if source.KV == nil {
+		return nil, nil, fmt.Errorf("vault kv or aws must be set")
+	}
+	data, err := c.readKV(source.KV)
+	if err != nil {
+		log.Info("Unable to read secret from Vault", "path", source.KV.Path)
+		forgetRevokedVaultClient(key, c, err)
+		return nil, nil, err
+	}
+	for _, key := range []string{"accessKeyId", "secretAccessKey"} {
+		if data[key] == "" {
+			return nil, nil, fmt.Errorf("key %q not found in Vault secret %q", key, source.KV.Path)
+		}
+	}
+
+	return credentials.NewStaticCredentials(data["accessKeyId"], data["secretAccessKey"], data["sessionToken"]), nil, nil
+}
+
+// getVaultBasicAuth returns the username and password of the KV secret of the Vault source
+func (r *CredentialsReconciler) getVaultBasicAuth(log logr.Logger, namespace string, source *registryv1alpha1.VaultSource) (string, string, error) {
+	if source.KV == nil {
+		return "", "", fmt.Errorf("vault kv must be set")
+	}
+
+	c, key, err := r.getVaultClient(log, namespace, source)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := c.readKV(source.KV)
+	if err != nil {
+		log.Info("Unable to read secret from Vault", "path", source.KV.Path)
+		forgetRevokedVaultClient(key, c, err)
+		return "", "", err
+	}
+	for _, key := range []string{"username", "password"} {
+		if data[key] == "" {
+			return "", "", fmt.Errorf("key %q not found in Vault secret %q", key, source.KV.Path)
+		}
+	}
+
+	return data["username"], data["password"], nil
+}
+
+// forgetRevokedVaultClient logs in again on the next read when the token of the client was rejected
+func forgetRevokedVaultClient(key string, c *vaultClient, err error) {
+	if isHTTPStatus(err, http.StatusForbidden) {
+		vaultClients.forget(key, c)
+	}
+}
+
+// getVaultIdentityKey returns a key identifying the credentials read from the Vault source
+func getVaultIdentityKey(namespace string, source *registryv1alpha1.VaultSource) []string {
+	parts := []string{"vault", namespace, source.Address, source.Namespace,
+		source.Auth.MountPath, source.Auth.Role, source.Auth.ServiceAccountName, source.Auth.Audience}
+	if source.KV != nil {
+		parts = append(parts, "kv", source.KV.MountPath, source.KV.Path)
+	}
+	if source.AWS != nil {
+		parts = append(parts, "aws", source.AWS.MountPath, source.AWS.Role)
+	}
+	return parts
+}
+
+// capExpiration returns expiresAt, or limit when it happens earlier, e.g. the expiration
+// of the Vault lease of the AWS credentials a token was requested with
+func capExpiration(expiresAt, limit *time.Time) *time.Time {
+	if limit == nil {
+		return expiresAt
+	}
+	if expiresAt == nil || limit.Before(*expiresAt) {
+		return limit
+	}
+	return expiresAt
+}
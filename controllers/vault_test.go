@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Vault", func() {
+
+	// newVaultServer returns a stand-in of Vault with the Kubernetes auth method
+	// enabled, a KV version 2 engine and an AWS secrets engine
+	newVaultServer := func() *httptest.Server {
+		leases := 0
+		mux := http.NewServeMux()
+		authorized := func(w http.ResponseWriter, req *http.Request) bool {
+			defer GinkgoRecover()
+			Expect(req.Header.Get("X-Vault-Namespace")).Should(Equal("team-a"))
+			if req.Header.Get("X-Vault-Token") != "s.client-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return false
+			}
+			return true
+		}
+		mux.HandleFunc("/v1/auth/kubernetes/login", func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.Method).Should(Equal(http.MethodPost))
+			body := map[string]string{}
+			Expect(json.NewDecoder(req.Body).Decode(&body)).Should(Succeed())
+			if body["role"] != "registry-controller" || body["jwt"] != "service-account-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"s.client-token","lease_duration":3600}}`))
+		})
+		mux.HandleFunc("/v1/secret/data/registry/harbor", func(w http.ResponseWriter, req *http.Request) {
+			if authorized(w, req) {
+				w.Write([]byte(`{"data":{"data":{"username":"robot$puller","password":"secret"},"metadata":{"version":1}}}`))
+			}
+		})
+		mux.HandleFunc("/v1/aws/creds/ecr-reader", func(w http.ResponseWriter, req *http.Request) {
+			if authorized(w, req) {
+				leases++
+				fmt.Fprintf(w, `{"lease_id":"aws/creds/ecr-reader/%d","lease_duration":900,"renewable":true,"data":{"access_key":"ASIAEXAMPLE%d","secret_key":"secret","security_token":"session-token"}}`, leases, leases)
+			}
+		})
+		mux.HandleFunc("/v1/aws/creds/static", func(w http.ResponseWriter, req *http.Request) {
+			if authorized(w, req) {
+				w.Write([]byte(`{"lease_duration":0,"data":{"access_key":"AKIAEXAMPLE","secret_key":"secret"}}`))
+			}
+		})
+		mux.HandleFunc("/v1/sys/leases/renew", func(w http.ResponseWriter, req *http.Request) {
+			if authorized(w, req) {
+				// Leases are renewed up to their max TTL
+				w.Write([]byte(`{"lease_id":"aws/creds/ecr-reader/1","lease_duration":60,"renewable":false}`))
+			}
+		})
+		mux.HandleFunc("/v1/sys/leases/revoke", func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Fail("leases may back the credentials of other objects")
+		})
+		mux.HandleFunc("/v1/auth/token/revoke-self", func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Fail("tokens may back the credentials of other objects")
+		})
+		return httptest.NewServer(mux)
+	}
+
+	Context("When reading credentials from Vault", func() {
+		It("Should log in with the Kubernetes auth method", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			c := &vaultClient{address: server.URL, namespace: "team-a"}
+			Expect(c.login("", "registry-controller", "service-account-token")).Should(Succeed())
+			Expect(c.token).Should(Equal("s.client-token"))
+
+			err := (&vaultClient{address: server.URL, namespace: "team-a"}).login("kubernetes", "registry-controller", "invalid")
+			Expect(err).To(HaveOccurred())
+			Expect(isUnauthorized(err)).Should(BeTrue())
+			Expect(isTransient(err)).Should(BeFalse())
+		})
+
+		It("Should read KV version 2 secrets", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			c := &vaultClient{address: server.URL, namespace: "team-a", token: "s.client-token"}
+			data, err := c.readKV(&registryv1alpha1.VaultKVSecret{Path: "registry/harbor"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).Should(Equal(map[string]string{"username": "robot$puller", "password": "secret"}))
+		})
+
+		It("Should request dynamic AWS credentials expiring with their lease", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			c := &vaultClient{address: server.URL, namespace: "team-a", token: "s.client-token"}
+			value, expiresAt, err := c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).Should(Equal("ASIAEXAMPLE1"))
+			Expect(value.SessionToken).Should(Equal("session-token"))
+			Expect(expiresAt).Should(BeTemporally("~", time.Now().Add(15*time.Minute), time.Minute))
+
+			provider := &vaultCredentialsProvider{value: value}
+			provider.SetExpiration(expiresAt, vaultLeaseExpiryWindow)
+			Expect(credentials.NewCredentials(provider).Get()).Should(Equal(value))
+
+			By("By expiring with the token when it expires earlier")
+			c.tokenExpiresAt = time.Now().Add(5 * time.Minute)
+			_, expiresAt, err = c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiresAt).Should(Equal(c.tokenExpiresAt))
+		})
+
+		It("Should not expire credentials without lease duration", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			c := &vaultClient{address: server.URL, namespace: "team-a", token: "s.client-token"}
+			value, expiresAt, err := c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "static"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).Should(Equal("AKIAEXAMPLE"))
+			Expect(expiresAt.IsZero()).Should(BeTrue())
+		})
+
+		It("Should reuse leases until half their lifetime and keep them when replaced", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			c := &vaultClient{address: server.URL, namespace: "team-a", token: "s.client-token"}
+			first, _, err := c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			value, _, err := c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).Should(Equal(first))
+
+			By("By renewing leases after half their lifetime")
+			c.leases["aws/creds/ecr-reader"].renewAt = time.Now().Add(-time.Minute)
+			value, expiresAt, err := c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).Should(Equal(first))
+			Expect(expiresAt).Should(BeTemporally("~", time.Now().Add(time.Minute), 10*time.Second))
+
+			By("By replacing leases that can't be renewed any more")
+			c.leases["aws/creds/ecr-reader"].renewAt = time.Now().Add(-time.Minute)
+			value, _, err = c.readAWS(&registryv1alpha1.VaultAWSSecret{Role: "ecr-reader"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).Should(Equal("ASIAEXAMPLE2"))
+		})
+	})
+
+	Context("When logging in with a ServiceAccount token", func() {
+		It("Should request the token for the Vault audience", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			var audiences []string
+			r := &CredentialsReconciler{Clientset: newTokenClientset("service-account-token", &audiences)}
+			source := &registryv1alpha1.VaultSource{
+				Address:   server.URL,
+				Namespace: "team-a",
+				Auth: registryv1alpha1.VaultKubernetesAuth{
+					ServiceAccountName: "vault-reader",
+					Role:               "registry-controller",
+				},
+			}
+
+			c, err := r.newVaultClient(ctrl.Log.WithName("vault"), "default", source)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.token).Should(Equal("s.client-token"))
+			Expect(audiences).Should(Equal([]string{"vault"}))
+
+			source.Auth.Audience = "https://vault.example.com"
+			_, err = r.newVaultClient(ctrl.Log.WithName("vault"), "default", source)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(audiences).Should(Equal([]string{"https://vault.example.com"}))
+		})
+	})
+
+	Context("When caching Vault clients", func() {
+		log := ctrl.Log.WithName("vault")
+
+		It("Should reuse tokens while they are valid and let them expire when logging in again", func() {
+			server := newVaultServer()
+			defer server.Close()
+
+			logins := 0
+			login := func() (*vaultClient, error) {
+				logins++
+				c := &vaultClient{address: server.URL, namespace: "team-a"}
+				return c, c.login("", "registry-controller", "service-account-token")
+			}
+			cache := newVaultClientCache()
+
+			c, err := cache.get(log, "team-a", login)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.tokenExpiresAt).Should(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+			Expect(cache.get(log, "team-a", login)).Should(BeIdenticalTo(c))
+			Expect(logins).Should(Equal(1))
+
+			By("By logging in again after half the lifetime of tokens that can't be renewed")
+			c.tokenRenewAt = time.Now().Add(-time.Minute)
+			next, err := cache.get(log, "team-a", login)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(next).ShouldNot(BeIdenticalTo(c))
+			Expect(logins).Should(Equal(2))
+
+			By("By logging in again when the token was revoked")
+			cache.forget("team-a", next)
+			Expect(cache.get(log, "team-a", login)).ShouldNot(BeIdenticalTo(next))
+			Expect(logins).Should(Equal(3))
+		})
+
+		It("Should not wait for the logins of other keys", func() {
+			cache := newVaultClientCache()
+			team := &vaultClient{token: "s.team-token"}
+			Expect(cache.get(log, "team-a", func() (*vaultClient, error) { return team, nil })).Should(BeIdenticalTo(team))
+
+			unreachable := make(chan struct{})
+			defer close(unreachable)
+			logins := make(chan struct{}, 2)
+			for i := 0; i < 2; i++ {
+				go cache.get(log, "team-b", func() (*vaultClient, error) {
+					logins <- struct{}{}
+					<-unreachable
+					return nil, fmt.Errorf("timeout")
+				})
+			}
+			Eventually(logins).Should(Receive())
+
+			Expect(cache.get(log, "team-a", func() (*vaultClient, error) {
+				Fail("unexpected login")
+				return nil, nil
+			})).Should(BeIdenticalTo(team))
+			Consistently(logins).ShouldNot(Receive())
+		})
+
+		It("Should cap token expirations with the expiration of the credentials", func() {
+			later := time.Now().Add(12 * time.Hour)
+			earlier := time.Now().Add(time.Minute)
+			Expect(capExpiration(&later, &earlier)).Should(Equal(&earlier))
+			Expect(capExpiration(&earlier, &later)).Should(Equal(&earlier))
+			Expect(capExpiration(&later, nil)).Should(Equal(&later))
+			Expect(capExpiration(nil, &earlier)).Should(Equal(&earlier))
+		})
+	})
+})
+
+// newTokenClientset returns a clientset issuing token for the TokenRequests of every
+// ServiceAccount, recording their audiences
+func newTokenClientset(token string, audiences *[]string) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tokenRequest := create.GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+		*audiences = tokenRequest.Spec.Audiences
+		tokenRequest.Status.Token = token
+		return true, tokenRequest, nil
+	})
+	return clientset
+}
@@ -27,6 +27,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -84,10 +85,11 @@ func main() {
 	}
 
 	credentialsReconciler := controllers.CredentialsReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("ECRCredentials"),
-		Recorder: mgr.GetEventRecorderFor("credentials-controller"),
-		Scheme:   mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Clientset: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		Log:       ctrl.Log.WithName("controllers").WithName("ECRCredentials"),
+		Recorder:  mgr.GetEventRecorderFor("credentials-controller"),
+		Scheme:    mgr.GetScheme(),
 	}
 
 	if err = (&controllers.ECRCredentialsReconciler{
@@ -101,6 +103,83 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (&controllers.ECRPublicCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("ECRPublicCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("ecr-public-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ECRPublicCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.GCRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("GCRCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("gcr-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GCRCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ACRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("ACRCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("acr-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ACRCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.GHCRCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("GHCRCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("ghcr-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GHCRCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.RegistryCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("RegistryCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("registry-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RegistryCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.HarborRobotCredentialsReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("HarborRobotCredentials"),
+		Recorder:              mgr.GetEventRecorderFor("harbor-robot-credentials-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HarborRobotCredentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.RegistryCredentialsSetReconciler{
+		CredentialsReconciler: credentialsReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("RegistryCredentialsSet"),
+		Recorder:              mgr.GetEventRecorderFor("registry-credentials-set-controller"),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RegistryCredentialsSet")
+		os.Exit(1)
+	}
+
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		setupLog.Info("set up webhook")
 		mutatePodWebhook := &webhooks.MutatePodWebhook{
@@ -113,6 +192,30 @@ func main() {
 			setupLog.Error(err, "unable to create webhook", "webhook", "ECRCredentials")
 			os.Exit(1)
 		}
+		if err = (&registryv1alpha1.ECRPublicCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ECRPublicCredentials")
+			os.Exit(1)
+		}
+		if err = (&registryv1alpha1.GCRCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GCRCredentials")
+			os.Exit(1)
+		}
+		if err = (&registryv1alpha1.ACRCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ACRCredentials")
+			os.Exit(1)
+		}
+		if err = (&registryv1alpha1.GHCRCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GHCRCredentials")
+			os.Exit(1)
+		}
+		if err = (&registryv1alpha1.RegistryCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RegistryCredentials")
+			os.Exit(1)
+		}
+		if err = (&registryv1alpha1.HarborRobotCredentials{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HarborRobotCredentials")
+			os.Exit(1)
+		}
 	}
 
 	//+kubebuilder:scaffold:builder
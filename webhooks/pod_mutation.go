@@ -14,6 +14,7 @@ import (
 	registryv1alpha1 "github.com/astrokube/registry-controller/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 type MutatePodWebhook struct {
@@ -47,15 +48,29 @@ func (w *MutatePodWebhook) Handle(ctx context.Context, req admission.Request) ad
 	// Get secrets to inject in the pod
 	secretsToAdd := []string{}
 	for _, image := range images {
-		ecrSecrets, err := w.getSecretNamesForECRCredentials(image, pod.ObjectMeta.Namespace)
+		credentialsSecrets, err := w.getSecretNamesForCredentials(image, pod.ObjectMeta.Namespace)
 		if err != nil {
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
-		secretsToAdd = append(secretsToAdd, ecrSecrets...)
+		secretsToAdd = append(secretsToAdd, credentialsSecrets...)
+
+		setSecrets, err := w.getSecretNamesForRegistryCredentialsSets(image, pod.ObjectMeta.Namespace)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		secretsToAdd = append(secretsToAdd, setSecrets...)
 	}
 
-	// Inject secrets
+	// Inject secrets, once each
+	injected := map[string]bool{}
+	for _, imagePullSecret := range pod.Spec.ImagePullSecrets {
+		injected[imagePullSecret.Name] = true
+	}
 	for _, secret := range secretsToAdd {
+		if injected[secret] {
+			continue
+		}
+		injected[secret] = true
 		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{
 			Name: secret,
 		})
@@ -75,22 +90,45 @@ func (w *MutatePodWebhook) InjectDecoder(d *admission.Decoder) error {
 	return nil
 }
 
-func (w *MutatePodWebhook) getSecretNamesForECRCredentials(image, namespace string) ([]string, error) {
-	ecrCredentialsList, err := w.getECRCredentialsList(namespace)
-	if err != nil {
-		return nil, err
-	}
+// credentialsLists create the lists of every credentials kind generating a Secret
+var credentialsLists = []func() client.ObjectList{
+	func() client.ObjectList { return &registryv1alpha1.ECRCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.ECRPublicCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.GCRCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.ACRCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.GHCRCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.RegistryCredentialsList{} },
+	func() client.ObjectList { return &registryv1alpha1.HarborRobotCredentialsList{} },
+}
 
+// getSecretNamesForCredentials returns the Secrets of the credentials of every kind in
+// the namespace whose imageSelector matches the image
+func (w *MutatePodWebhook) getSecretNamesForCredentials(image, namespace string) ([]string, error) {
 	secretNames := []string{}
 
-	for _, ecrCredentials := range ecrCredentialsList.Items {
-		for _, imageSelector := range ecrCredentials.Spec.ImageSelector {
-			match, err := regexp.Match(imageSelector, []byte(image))
+	for _, newList := range credentialsLists {
+		list := newList()
+		err := w.Client.List(context.TODO(), list, &client.ListOptions{Namespace: namespace})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			credentials, ok := item.(credentialsObject)
+			if !ok || !isPullSecret(credentials) {
+				continue
+			}
+			match, err := matchImageSelector(image, credentials.GetCredentialsSpec().ImageSelector)
 			if err != nil {
 				return nil, err
 			}
 			if match {
-				secretNames = append(secretNames, ecrCredentials.ObjectMeta.Name)
+				secretNames = append(secretNames, getCredentialsSecretName(credentials))
 			}
 		}
 	}
@@ -98,12 +136,89 @@ func (w *MutatePodWebhook) getSecretNamesForECRCredentials(image, namespace stri
 	return secretNames, nil
 }
 
-func (w *MutatePodWebhook) getECRCredentialsList(namespace string) (*registryv1alpha1.ECRCredentialsList, error) {
-	list := &registryv1alpha1.ECRCredentialsList{}
-	err := w.Client.List(context.TODO(), list, &client.ListOptions{Namespace: namespace})
+func (w *MutatePodWebhook) getSecretNamesForRegistryCredentialsSets(image, namespace string) ([]string, error) {
+	setList := &registryv1alpha1.RegistryCredentialsSetList{}
+	err := w.Client.List(context.TODO(), setList, &client.ListOptions{Namespace: namespace})
 	if err != nil && !errors.IsNotFound(err) {
 		return nil, err
 	}
 
-	return list, nil
+	secretNames := []string{}
+
+	for _, set := range setList.Items {
+		match, err := matchImageSelector(image, set.Spec.ImageSelector)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			secretName := set.Status.SecretName
+			if secretName == "" {
+				secretName = set.Spec.SecretName
+			}
+			if secretName == "" {
+				secretName = set.ObjectMeta.Name
+			}
+			secretNames = append(secretNames, secretName)
+		}
+	}
+
+	return secretNames, nil
+}
+
+// credentialsObject is a credentials kind generating a Secret
+type credentialsObject interface {
+	GetName() string
+	GetCredentialsSpec() *registryv1alpha1.CredentialsSpec
+	GetCredentialsStatus() *registryv1alpha1.CredentialsStatus
+}
+
+// getCredentialsSecretName returns the name of the Secret generated for the
+// credentials, which may be customized through their secretTemplate
+func getCredentialsSecretName(credentials credentialsObject) string {
+	if secretName := credentials.GetCredentialsStatus().SecretName; secretName != "" {
+		return secretName
+	}
+	if secretTemplate := credentials.GetCredentialsSpec().SecretTemplate; secretTemplate != nil && secretTemplate.Name != "" {
+		return secretTemplate.Name
+	}
+
+	return credentials.GetName()
+}
+
+// isPullSecret returns true if the Secret generated for the credentials can be used as an
+// image pull secret, i.e. its format is dockerconfigjson or dockercfg and its type is not
+// overridden with another one, e.g. Opaque
+func isPullSecret(credentials credentialsObject) bool {
+	secretTemplate := credentials.GetCredentialsSpec().SecretTemplate
+	if secretTemplate == nil {
+		return true
+	}
+
+	switch secretTemplate.Type {
+	case "", corev1.SecretTypeDockerConfigJson, corev1.SecretTypeDockercfg:
+	default:
+		return false
+	}
+
+	switch secretTemplate.Format {
+	case "", registryv1alpha1.SecretFormatDockerConfigJSON, registryv1alpha1.SecretFormatDockerCfg:
+		return true
+	default:
+		return false
+	}
+}
+
+// matchImageSelector returns true if the image matches any of the imageSelector regexps
+func matchImageSelector(image string, imageSelector []string) (bool, error) {
+	for _, selector := range imageSelector {
+		match, err := regexp.Match(selector, []byte(image))
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }